@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	domainNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	fieldNamePattern  = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// fieldTypes maps --fields type names to Go types, default gorm tags and the
+// Postgres column types of the generated migration.
+var fieldTypes = map[string]struct {
+	goType  string
+	gorm    string
+	sqlType string
+}{
+	"string":  {goType: "string", gorm: "size:255", sqlType: "varchar(255)"},
+	"text":    {goType: "string", gorm: "type:text", sqlType: "text"},
+	"int":     {goType: "int", sqlType: "bigint"},
+	"int32":   {goType: "int32", sqlType: "integer"},
+	"int64":   {goType: "int64", sqlType: "bigint"},
+	"uint":    {goType: "uint", sqlType: "bigint"},
+	"uint64":  {goType: "uint64", sqlType: "bigint"},
+	"float64": {goType: "float64", sqlType: "double precision"},
+	"bool":    {goType: "bool", sqlType: "boolean"},
+	"time":    {goType: "time.Time", sqlType: "timestamptz"},
+}
+
+type domainSpec struct {
+	Module string
+	Name   string // raw snake_case name, e.g. order_item
+	Pkg    string // package name, e.g. orderitem
+	Type   string // exported type name, e.g. OrderItem
+	Plural string // lowerCamel plural, e.g. orderItems
+	Route  string // URL segment, e.g. order-items
+	Label  string // human readable, e.g. order item
+	Table  string // gorm table name, e.g. order_item_entities
+	Fields []fieldSpec
+}
+
+type fieldSpec struct {
+	Name   string
+	JSON   string
+	GoType string
+	Gorm   string
+	Column string
+	SQL    string
+}
+
+func (d domainSpec) NeedsTime() bool {
+	for _, f := range d.Fields {
+		if f.GoType == "time.Time" {
+			return true
+		}
+	}
+	return false
+}
+
+func handleAdd(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing add target; supported: domain")
+	}
+	switch args[0] {
+	case "domain":
+		return handleAddDomain(args[1:])
+	default:
+		return fmt.Errorf("unknown add target: %s", args[0])
+	}
+}
+
+func handleAddDomain(args []string) error {
+	fs := flag.NewFlagSet("add domain", flag.ContinueOnError)
+	fields := fs.String("fields", "", "comma separated field list, e.g. name:string,price:int64")
+	root := fs.String("root", ".", "project root containing go.mod")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gt add domain <name> [--fields name:string,price:int64] [--root <path>]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("domain name is required")
+	}
+
+	rootDir, err := absolutePath(*root)
+	if err != nil {
+		return err
+	}
+	module, err := readModulePath(rootDir)
+	if err != nil {
+		return err
+	}
+
+	spec, err := newDomainSpec(module, positional[0], *fields)
+	if err != nil {
+		return err
+	}
+
+	if err := generateDomain(rootDir, spec, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n✅ Domain %s added\n", spec.Name)
+	fmt.Println("Next steps:")
+	fmt.Printf("  Review internal/domain/shared/%s and adjust validation tags\n", spec.Pkg)
+	fmt.Println("  go build ./...")
+	return nil
+}
+
+// parseInterspersed lets flags appear before or after positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func readModulePath(root string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("read go.mod: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "module ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "module ")), nil
+		}
+	}
+	return "", errors.New("go.mod has no module directive")
+}
+
+func newDomainSpec(module, name, fields string) (domainSpec, error) {
+	if !domainNamePattern.MatchString(name) {
+		return domainSpec{}, fmt.Errorf("invalid domain name %q: use lower snake_case", name)
+	}
+	words := strings.Split(name, "_")
+	spec := domainSpec{
+		Module: module,
+		Name:   name,
+		Pkg:    strings.Join(words, ""),
+		Type:   camel(words, true),
+		Plural: camel(pluralize(words), false),
+		Route:  strings.Join(pluralize(words), "-"),
+		Label:  strings.Join(words, " "),
+		Table:  name + "_entities",
+	}
+
+	seen := map[string]struct{}{"id": {}, "created_at": {}, "updated_at": {}}
+	for _, raw := range strings.Split(fields, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		fieldName, typeName, ok := strings.Cut(raw, ":")
+		if !ok {
+			typeName = "string"
+		}
+		if !fieldNamePattern.MatchString(fieldName) {
+			return domainSpec{}, fmt.Errorf("invalid field name %q: use lower snake_case", fieldName)
+		}
+		if _, dup := seen[fieldName]; dup {
+			return domainSpec{}, fmt.Errorf("duplicate or reserved field %q", fieldName)
+		}
+		seen[fieldName] = struct{}{}
+		ft, ok := fieldTypes[typeName]
+		if !ok {
+			return domainSpec{}, fmt.Errorf("unsupported type %q for field %q", typeName, fieldName)
+		}
+		spec.Fields = append(spec.Fields, fieldSpec{
+			Name:   camel(strings.Split(fieldName, "_"), true),
+			JSON:   fieldName,
+			GoType: ft.goType,
+			Gorm:   ft.gorm,
+			Column: fieldName,
+			SQL:    ft.sqlType,
+		})
+	}
+	return spec, nil
+}
+
+// generateDomain writes the domain's packages and the migration creating its
+// table, versioned at now.
+func generateDomain(root string, spec domainSpec, now time.Time) error {
+	shared := filepath.Join(root, "internal", "domain", "shared", spec.Pkg)
+	if exists(shared) {
+		return fmt.Errorf("domain %s already exists at %s", spec.Name, shared)
+	}
+
+	migration := filepath.Join(root, filepath.FromSlash(migrationsDir), now.Format("20060102150405")+"_create_"+spec.Table)
+	files := []struct {
+		tmpl string
+		dest string
+	}{
+		{"entity.go.tmpl", filepath.Join(shared, "entity", spec.Name+"_entity.go")},
+		{"model.go.tmpl", filepath.Join(shared, "model", spec.Name+".go")},
+		{"dto.go.tmpl", filepath.Join(shared, "dto", spec.Name+"_dto.go")},
+		{"service.go.tmpl", filepath.Join(shared, "service", spec.Name+"_service.go")},
+		{"client_handler.go.tmpl", filepath.Join(root, "internal", "domain", "client", spec.Pkg, "handler.go")},
+		{"console_handler.go.tmpl", filepath.Join(root, "internal", "domain", "console", spec.Pkg, "handler.go")},
+		{"migration.up.sql.tmpl", migration + ".up.sql"},
+		{"migration.down.sql.tmpl", migration + ".down.sql"},
+	}
+
+	rendered := make(map[string][]byte, len(files))
+	for _, f := range files {
+		if exists(f.dest) {
+			return fmt.Errorf("%s already exists", f.dest)
+		}
+		src, err := renderTemplate(f.tmpl, spec)
+		if err != nil {
+			return err
+		}
+		rendered[f.dest] = src
+	}
+
+	// Compute AST edits before touching the tree so a failure leaves it unchanged.
+	edits := map[string]func([]byte, domainSpec) ([]byte, error){
+		filepath.Join(root, "internal", "base", "repos", "registry.go"):   addRepoToRegistry,
+		filepath.Join(root, "internal", "domain", "client", "router.go"):  addClientRoutes,
+		filepath.Join(root, "internal", "domain", "console", "router.go"): addConsoleRoutes,
+	}
+	edited := make(map[string][]byte, len(edits))
+	for path, edit := range edits {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		out, err := edit(src, spec)
+		if err != nil {
+			return fmt.Errorf("edit %s: %w", path, err)
+		}
+		edited[path] = out
+	}
+
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.dest), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(f.dest, rendered[f.dest], 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", f.dest, err)
+		}
+		fmt.Printf("  create %s\n", relTo(root, f.dest))
+	}
+	for path, out := range edited {
+		if err := os.WriteFile(path, out, 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+		fmt.Printf("  update %s\n", relTo(root, path))
+	}
+	return nil
+}
+
+func renderTemplate(name string, spec domainSpec) ([]byte, error) {
+	tmpl, err := template.ParseFS(templateFS, "templates/"+name)
+	if err != nil {
+		return nil, fmt.Errorf("parse template %s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, spec); err != nil {
+		return nil, fmt.Errorf("render template %s: %w", name, err)
+	}
+	if !strings.HasSuffix(name, ".go.tmpl") {
+		return buf.Bytes(), nil
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format %s: %w", name, err)
+	}
+	return src, nil
+}
+
+func camel(words []string, exported bool) string {
+	var b strings.Builder
+	for i, w := range words {
+		if w == "" {
+			continue
+		}
+		if i == 0 && !exported {
+			b.WriteString(w)
+			continue
+		}
+		if w == "id" {
+			b.WriteString("ID")
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+// pluralize applies simple English rules to the last word.
+func pluralize(words []string) []string {
+	out := append([]string(nil), words...)
+	last := out[len(out)-1]
+	switch {
+	case strings.HasSuffix(last, "s"), strings.HasSuffix(last, "x"),
+		strings.HasSuffix(last, "ch"), strings.HasSuffix(last, "sh"):
+		last += "es"
+	case len(last) > 1 && strings.HasSuffix(last, "y") && !strings.ContainsAny(last[len(last)-2:len(last)-1], "aeiou"):
+		last = last[:len(last)-1] + "ies"
+	default:
+		last += "s"
+	}
+	out[len(out)-1] = last
+	return out
+}
+
+func relTo(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return normPath(rel)
+	}
+	return path
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrationTemplate(t *testing.T) {
+	spec, err := newDomainSpec("example.com/app", "order_item", "name:string,price:int64,paid_at:time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	up, err := renderTemplate("migration.up.sql.tmpl", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"CREATE TABLE order_item_entities (",
+		"id bigserial PRIMARY KEY,",
+		"name varchar(255),",
+		"price bigint,",
+		"paid_at timestamptz,",
+		"updated_at timestamptz\n);",
+	} {
+		if !strings.Contains(string(up), want) {
+			t.Errorf("up migration lacks %q:\n%s", want, up)
+		}
+	}
+	down, err := renderTemplate("migration.down.sql.tmpl", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(down)) != "DROP TABLE IF EXISTS order_item_entities;" {
+		t.Errorf("down migration = %q", down)
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestWireDomain runs the AST edits over copies of the project's registry
+// and routers; run with -update after changing them.
+func TestWireDomain(t *testing.T) {
+	spec, err := newDomainSpec("github.com/wiidz/gin_template", "order_item", "name:string")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file string
+		edit func([]byte, domainSpec) ([]byte, error)
+	}{
+		{"registry.go", addRepoToRegistry},
+		{"client_router.go", addClientRoutes},
+		{"console_router.go", addConsoleRoutes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.edit(src, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.file+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s differs from %s:\n%s", tt.file, golden, got)
+			}
+
+			// a second run must refuse rather than wire the domain twice
+			if _, err := tt.edit(got, spec); err == nil {
+				t.Errorf("editing %s twice succeeded", tt.file)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sourceFile couples a parsed Go file with its raw bytes so edits located via
+// the AST can be spliced into the original text, keeping comments intact.
+type sourceFile struct {
+	fset  *token.FileSet
+	file  *ast.File
+	src   []byte
+	edits []textEdit
+}
+
+type textEdit struct {
+	offset int
+	text   string
+}
+
+func parseSource(src []byte) (*sourceFile, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	return &sourceFile{fset: fset, file: f, src: src}, nil
+}
+
+func (s *sourceFile) offset(p token.Pos) int { return s.fset.Position(p).Offset }
+
+func (s *sourceFile) line(p token.Pos) int { return s.fset.Position(p).Line }
+
+func (s *sourceFile) insert(p token.Pos, text string) {
+	s.edits = append(s.edits, textEdit{offset: s.offset(p), text: text})
+}
+
+// render applies the queued edits and gofmts the result.
+func (s *sourceFile) render() ([]byte, error) {
+	edits := append([]textEdit(nil), s.edits...)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].offset > edits[j].offset })
+	out := append([]byte(nil), s.src...)
+	for _, e := range edits {
+		out = append(out[:e.offset], append([]byte(e.text), out[e.offset:]...)...)
+	}
+	formatted, err := format.Source(out)
+	if err != nil {
+		return nil, fmt.Errorf("format edited source: %w", err)
+	}
+	return formatted, nil
+}
+
+// ensureImport queues an import spec unless the path is already imported.
+// The spec joins the import group of the first path sharing module, if any,
+// so project-local imports stay grouped together.
+func (s *sourceFile) ensureImport(module, alias, path string) error {
+	var sibling *ast.ImportSpec
+	for _, imp := range s.file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		if p == path {
+			return nil
+		}
+		if strings.HasPrefix(p, module+"/") {
+			sibling = imp
+		}
+	}
+	spec := strconv.Quote(path)
+	if alias != "" {
+		spec = alias + " " + spec
+	}
+	if sibling != nil {
+		s.insert(sibling.End(), "\n\t"+spec)
+		return nil
+	}
+	for _, decl := range s.file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT || !gen.Lparen.IsValid() {
+			continue
+		}
+		s.insert(gen.Rparen, "\t"+spec+"\n")
+		return nil
+	}
+	return errors.New("no parenthesized import block found")
+}
+
+func (s *sourceFile) declaresTopLevel(name string) bool {
+	for _, decl := range s.file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			switch sp := spec.(type) {
+			case *ast.ValueSpec:
+				for _, n := range sp.Names {
+					if n.Name == name {
+						return true
+					}
+				}
+			case *ast.TypeSpec:
+				if sp.Name.Name == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (s *sourceFile) funcDecl(name string) (*ast.FuncDecl, error) {
+	for _, decl := range s.file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+			if fn.Body == nil {
+				return nil, fmt.Errorf("func %s has no body", name)
+			}
+			return fn, nil
+		}
+	}
+	return nil, fmt.Errorf("func %s not found", name)
+}
+
+// leadingPos returns the position of the comment block directly above stmt,
+// or the statement itself, so inserted code does not split a comment from
+// the line it describes.
+func (s *sourceFile) leadingPos(stmt ast.Node) token.Pos {
+	pos := stmt.Pos()
+	for i := len(s.file.Comments) - 1; i >= 0; i-- {
+		cg := s.file.Comments[i]
+		if cg.End() >= pos {
+			continue
+		}
+		if s.line(cg.End())+1 != s.line(pos) {
+			break
+		}
+		pos = cg.Pos()
+	}
+	return pos
+}
+
+func declaresIdent(n ast.Node, name string) bool {
+	found := false
+	ast.Inspect(n, func(node ast.Node) bool {
+		if as, ok := node.(*ast.AssignStmt); ok && as.Tok == token.DEFINE {
+			for _, lhs := range as.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name == name {
+					found = true
+				}
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// usesIdent reports whether n refers to the identifier name.
+func usesIdent(n ast.Node, name string) bool {
+	found := false
+	ast.Inspect(n, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// receiverName returns the identifier a statement such as `x.GET(...)` is
+// called on, walking through chained calls like `x.Group("").Use(...)`.
+func receiverName(stmt ast.Stmt) string {
+	es, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return ""
+	}
+	var expr ast.Expr = es.X
+	for {
+		switch e := expr.(type) {
+		case *ast.CallExpr:
+			expr = e.Fun
+		case *ast.SelectorExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// isHandlerCtor reports whether stmt looks like `h := pkg.NewXxxHandler(...)`.
+func isHandlerCtor(stmt ast.Stmt) bool {
+	as, ok := stmt.(*ast.AssignStmt)
+	if !ok || as.Tok != token.DEFINE || len(as.Rhs) != 1 {
+		return false
+	}
+	call, ok := as.Rhs[0].(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	return strings.HasPrefix(sel.Sel.Name, "New") && strings.HasSuffix(sel.Sel.Name, "Handler")
+}
+
+func addRepoToRegistry(src []byte, spec domainSpec) ([]byte, error) {
+	s, err := parseSource(src)
+	if err != nil {
+		return nil, err
+	}
+	if s.declaresTopLevel(spec.Type) {
+		return nil, fmt.Errorf("registry already declares %s", spec.Type)
+	}
+
+	entityAlias := spec.Pkg + "entity"
+	if err := s.ensureImport(spec.Module, entityAlias, spec.Module+"/internal/domain/shared/"+spec.Pkg+"/entity"); err != nil {
+		return nil, err
+	}
+
+	setup, err := s.funcDecl("Setup")
+	if err != nil {
+		return nil, err
+	}
+	anchor := setup.Pos()
+	if setup.Doc != nil {
+		anchor = setup.Doc.Pos()
+	}
+	repoType := fmt.Sprintf("*repoMng.Repo[%s.%sEntity]", entityAlias, spec.Type)
+	s.insert(anchor, fmt.Sprintf("// %s holds the %s repository.\nvar %s = struct {\n\tRepo %s\n}{}\n\n",
+		spec.Type, spec.Label, spec.Type, repoType))
+	s.insert(setup.Body.Rbrace, fmt.Sprintf("\t%s.Repo = repoMng.RepoOf[%s.%sEntity](M.Default().DB())\n",
+		spec.Type, entityAlias, spec.Type))
+	return s.render()
+}
+
+// addClientRoutes mounts the domain behind sagin.CheckLogin, like the
+// client's other resources.
+func addClientRoutes(src []byte, spec domainSpec) ([]byte, error) {
+	s, err := parseSource(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureImport(spec.Module, "sagin", "github.com/click33/sa-token-go/integrations/gin"); err != nil {
+		return nil, err
+	}
+	return addRoutes(s, spec, "client", "NewClientHandler", "v1", []string{
+		`Group("").Use(sagin.CheckLogin()).GET("/%[1]s", %[2]s.List)`,
+		`Group("").Use(sagin.CheckLogin()).GET("/%[1]s/:id", %[2]s.Get)`,
+	})
+}
+
+func addConsoleRoutes(src []byte, spec domainSpec) ([]byte, error) {
+	s, err := parseSource(src)
+	if err != nil {
+		return nil, err
+	}
+	return addRoutes(s, spec, "console", "NewConsoleHandler", "protected", []string{
+		`GET("/%[1]s", %[2]s.List)`,
+		`GET("/%[1]s/:id", %[2]s.Get)`,
+		`POST("/%[1]s", %[2]s.Create)`,
+		`PATCH("/%[1]s/:id", %[2]s.Update)`,
+		`DELETE("/%[1]s/:id", %[2]s.Delete)`,
+	})
+}
+
+// addRoutes wires a domain into a port router's Routes: the service and
+// handler are constructed after the last handler constructed before the
+// first route, and routes are appended after the last route registered on
+// group.
+func addRoutes(s *sourceFile, spec domainSpec, port, ctor, group string, routes []string) ([]byte, error) {
+	fn, err := s.funcDecl("Routes")
+	if err != nil {
+		return nil, err
+	}
+
+	svcVar := camel(strings.Split(spec.Name, "_"), false) + "Svc"
+	handlerVar := camel(strings.Split(spec.Name, "_"), false) + "H"
+	for _, v := range []string{svcVar, handlerVar} {
+		if declaresIdent(fn.Body, v) {
+			return nil, fmt.Errorf("Routes already declares %s", v)
+		}
+	}
+
+	handlerAlias := spec.Pkg + "handler"
+	svcAlias := spec.Pkg + "svc"
+	if err := s.ensureImport(spec.Module, "", spec.Module+"/internal/base/repos"); err != nil {
+		return nil, err
+	}
+	if err := s.ensureImport(spec.Module, handlerAlias, spec.Module+"/internal/domain/"+port+"/"+spec.Pkg); err != nil {
+		return nil, err
+	}
+	if err := s.ensureImport(spec.Module, svcAlias, spec.Module+"/internal/domain/shared/"+spec.Pkg+"/service"); err != nil {
+		return nil, err
+	}
+
+	// Constructors come before the first statement using the engine;
+	// handlers built further down belong to their route block.
+	engine := ""
+	if params := fn.Type.Params.List; len(params) > 0 && len(params[0].Names) > 0 {
+		engine = params[0].Names[0].Name
+	}
+	var lastCtor, firstRoute ast.Stmt
+	for _, stmt := range fn.Body.List {
+		if usesIdent(stmt, engine) {
+			firstRoute = stmt
+			break
+		}
+		if isHandlerCtor(stmt) {
+			lastCtor = stmt
+		}
+	}
+	var routeBlock *ast.BlockStmt
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if b, ok := n.(*ast.BlockStmt); ok {
+			for _, stmt := range b.List {
+				if receiverName(stmt) == group || declaresIdent(stmt, group) {
+					routeBlock = b
+				}
+			}
+		}
+		return true
+	})
+	if routeBlock == nil {
+		return nil, fmt.Errorf("Routes has no %q route group", group)
+	}
+	var lastRoute ast.Stmt
+	for _, stmt := range routeBlock.List {
+		if receiverName(stmt) == group {
+			lastRoute = stmt
+		}
+	}
+
+	construct := fmt.Sprintf("%s := %s.New(repos.%s.Repo)\n%s := %s.%s(%s)\n",
+		svcVar, svcAlias, spec.Type, handlerVar, handlerAlias, ctor, svcVar)
+	switch {
+	case lastCtor != nil:
+		s.insert(lastCtor.End(), "\n"+strings.TrimSuffix(construct, "\n"))
+	case firstRoute != nil:
+		s.insert(s.leadingPos(firstRoute), construct+"\n")
+	default:
+		return nil, errors.New("Routes registers no routes to anchor handler construction")
+	}
+
+	var lines []string
+	for _, r := range routes {
+		lines = append(lines, group+"."+fmt.Sprintf(r, spec.Route, handlerVar))
+	}
+	block := strings.Join(lines, "\n")
+	switch {
+	case lastRoute != nil:
+		s.insert(lastRoute.End(), "\n\n"+block)
+	default:
+		// group is declared but has no routes yet; append at the end of its block
+		// (before a trailing return, if any).
+		last := routeBlock.List[len(routeBlock.List)-1]
+		if _, ok := last.(*ast.ReturnStmt); ok {
+			s.insert(s.leadingPos(last), block+"\n")
+		} else {
+			s.insert(last.End(), "\n"+block)
+		}
+	}
+	return s.render()
+}
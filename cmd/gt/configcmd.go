@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func handleConfig(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	env := fs.String("env", "", "validate with this profile, e.g. prod")
+	root := fs.String("root", ".", "project root containing go.mod")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gt config validate [--env prod] [--root <path>]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || positional[0] != "validate" {
+		fs.Usage()
+		return errors.New("supported config subcommands: validate")
+	}
+
+	rootDir, err := absolutePath(*root)
+	if err != nil {
+		return err
+	}
+	if _, err := readModulePath(rootDir); err != nil {
+		return err
+	}
+
+	serverArgs := []string{"-check-config"}
+	if *env != "" {
+		serverArgs = append(serverArgs, "-env", *env)
+	}
+	// The project's own server binary performs the check so its config schema
+	// and validation rules are used, without opening any listener.
+	return runProjectCommand(rootDir, "./cmd/server", serverArgs, nil)
+}
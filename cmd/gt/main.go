@@ -38,6 +38,21 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+	case "add":
+		if err := handleAdd(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "migrate":
+		if err := handleMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "config":
+		if err := handleConfig(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	case "help", "-h", "--help":
 		usage()
 	default:
@@ -52,13 +67,31 @@ func usage() {
 
 Usage:
   gt new <project-name> [flags]
+  gt add domain <name> [--fields name:string,price:int64] [--root <path>]
+  gt migrate up | down [steps] | status | create <name> [--root <path>]
+  gt config validate [--env prod] [--root <path>]
 
-Flags:
+Flags (new):
   --module <path>     Override module path (default: <project-name>)
   --dir <path>        Target directory to create project in (default: sibling to template root)
   --template <path>   Template directory (default: detected relative to binary or $GIN_TEMPLATE_ROOT)
   --skip-git          Do not run git init
   --skip-tidy         Do not run go mod tidy
+
+Flags (add domain):
+  --fields <list>     Comma separated name:type pairs; types: string, text, int, int32,
+                      int64, uint, uint64, float64, bool, time
+  --root <path>       Project root containing go.mod (default: .)
+
+Flags (migrate):
+  --root <path>       Project root containing go.mod (default: .)
+                      up/down/status run the project's ./cmd/migrate; create writes
+                      internal/base/migrations/sql/<timestamp>_<name>.{up,down}.sql
+
+Flags (config validate):
+  --env <name>        Validate as if env were <name> (e.g. prod)
+  --root <path>       Project root containing go.mod (default: .)
+
   -h, --help          Show this help message`)
 }
 
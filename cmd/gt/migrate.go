@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+const migrationsDir = "internal/base/migrations/sql"
+
+var migrationNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+func handleMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	root := fs.String("root", ".", "project root containing go.mod")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gt migrate up | down [steps] | status | create <name> [--root <path>]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return errors.New("migrate subcommand is required")
+	}
+
+	rootDir, err := absolutePath(*root)
+	if err != nil {
+		return err
+	}
+	if _, err := readModulePath(rootDir); err != nil {
+		return err
+	}
+
+	switch positional[0] {
+	case "create":
+		if len(positional) != 2 {
+			return errors.New("migration name is required")
+		}
+		return createMigration(rootDir, positional[1], time.Now().UTC())
+	case "up", "down", "status":
+		return runProjectMigrate(rootDir, positional)
+	default:
+		return fmt.Errorf("unknown migrate subcommand: %s", positional[0])
+	}
+}
+
+// runProjectMigrate delegates to the project's own cmd/migrate so that Go
+// migrations compiled into the project are included.
+func runProjectMigrate(root string, args []string) error {
+	return runProjectCommand(root, "./cmd/migrate", args, nil)
+}
+
+// runProjectCommand executes `go run <pkg> args...` inside the project root.
+func runProjectCommand(root, pkg string, args, env []string) error {
+	if _, err := exec.LookPath("go"); err != nil {
+		return fmt.Errorf("Go toolchain not found; cannot run %s", pkg)
+	}
+	if !exists(filepath.Join(root, filepath.FromSlash(pkg))) {
+		return fmt.Errorf("%s has no %s", root, pkg)
+	}
+	cmd := exec.Command("go", append([]string{"run", pkg}, args...)...)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+func createMigration(root, name string, now time.Time) error {
+	if !migrationNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid migration name %q: use lower snake_case", name)
+	}
+	dir := filepath.Join(root, filepath.FromSlash(migrationsDir))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	base := fmt.Sprintf("%s_%s", now.Format("20060102150405"), name)
+	for _, suffix := range []string{".up.sql", ".down.sql"} {
+		path := filepath.Join(dir, base+suffix)
+		if exists(path) {
+			return fmt.Errorf("%s already exists", path)
+		}
+		body := fmt.Sprintf("-- %s%s\n", base, suffix)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+		fmt.Printf("  create %s\n", relTo(root, path))
+	}
+	return nil
+}
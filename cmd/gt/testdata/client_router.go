@@ -0,0 +1,36 @@
+package client
+
+import (
+	"net/http"
+
+	sagin "github.com/click33/sa-token-go/integrations/gin"
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/base/repos"
+	userhandler "github.com/wiidz/gin_template/internal/domain/client/user"
+	usersvc "github.com/wiidz/gin_template/internal/domain/shared/user/service"
+
+	idmng "github.com/wiidz/goutil/mngs/identityMng"
+)
+
+// Routes registers the client port's handlers.
+func Routes(e *gin.Engine) {
+	// repos.Setup 应在 server/main 处传入
+	// 通用仓储直接传入 service
+	uRepo := repos.User.Repo
+	mng, _ := idmng.NewMng(&idmng.Config{DefaultDevice: "client"})
+	uSvc := usersvc.New(uRepo, usersvc.NewTokenStore(repos.User.DB), mng)
+	clientH := userhandler.NewClientHandler(uSvc)
+
+	e.GET("/health", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+
+	// 额外业务接口
+	v1 := e.Group("/api/v1")
+	v1.GET("/ping", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"message": "pong"}) })
+	v1.Group("").Use(sagin.CheckLogin()).GET("/user/me", clientH.Me)
+
+	auth := v1.Group("/auth")
+	auth.POST("/login", clientH.Login)
+	auth.POST("/refresh", clientH.Refresh)
+	auth.Group("").Use(sagin.CheckLogin()).POST("/logout", clientH.Logout)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/wiidz/gin_template/internal/base/config"
+	"github.com/wiidz/gin_template/internal/base/migrations"
+
+	"github.com/wiidz/goutil/mngs/psqlMng"
+)
+
+// migrate applies the project's migrations against config.C.DB.DSN.
+// It is usually invoked through `gt migrate up|down|status`.
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: migrate up | down [steps] | status")
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd string, args []string) error {
+	config.Init(config.Options{})
+	if config.C.DB.DSN == "" {
+		return errors.New("db.dsn is empty")
+	}
+	psql, err := psqlMng.NewMng(&psqlMng.Config{DSN: config.C.DB.DSN})
+	if err != nil {
+		return err
+	}
+	defer psql.Close()
+
+	m, err := migrations.New(psql.DB())
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch cmd {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", len(applied))
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps %q", args[0])
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %d migration(s)\n", len(reverted))
+	case "status":
+		rows, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, r := range rows {
+			applied := "pending"
+			if r.AppliedAt != nil {
+				applied = r.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if r.Missing {
+				applied += " (missing from binary)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", r.Version, r.Name, applied)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	return nil
+}
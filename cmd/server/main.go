@@ -2,59 +2,155 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 
 	"github.com/wiidz/gin_template/internal/base/app"
 	"github.com/wiidz/gin_template/internal/base/config"
+	"github.com/wiidz/gin_template/internal/base/migrations"
 	"github.com/wiidz/gin_template/internal/base/repos"
 	"github.com/wiidz/gin_template/internal/base/server"
+	"github.com/wiidz/gin_template/internal/common/firewall"
 	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/metrics"
+	"github.com/wiidz/gin_template/internal/common/middleware"
+	"github.com/wiidz/gin_template/internal/common/ratelimit"
+	"github.com/wiidz/gin_template/internal/common/tracing"
+	clientport "github.com/wiidz/gin_template/internal/domain/client"
+	consoleport "github.com/wiidz/gin_template/internal/domain/console"
+	fwsvc "github.com/wiidz/gin_template/internal/domain/shared/firewall/service"
 )
 
+// ports lists every HTTP surface served by this binary. Each needs a
+// ports.<name> entry in config; add a Port here to serve another one.
+var ports = []server.Port{
+	{Name: "client", Routes: clientport.Routes},
+	{Name: "console", Routes: consoleport.Routes},
+}
+
 func main() {
-	config.Init()
-	logger.Init(config.C.Env)
+	var overrides setFlags
+	env := flag.String("env", "", "config profile; loads configs/config.<env>.yaml over config.yaml")
+	configDir := flag.String("config-dir", "", "directory containing config.yaml (default: . or ./configs)")
+	flag.Var(&overrides, "set", "override a config key, e.g. -set ports.client.port=9000 (repeatable)")
+	checkConfig := flag.Bool("check-config", false, "validate the config and exit")
+	printConfig := flag.Bool("print-config", false, "print the effective config with its source layers and exit")
+	flag.Parse()
+
+	opts := config.Options{Env: *env, Dir: *configDir, Overrides: overrides}
+
+	if *checkConfig || *printConfig {
+		_, eff, err := config.Load(opts)
+		if *printConfig && eff != nil {
+			_ = eff.Write(os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "config: %v\n", err)
+			os.Exit(1)
+		}
+		if *checkConfig {
+			fmt.Println("config OK")
+		}
+		return
+	}
+
+	config.Init(opts)
+	lc := config.C.Log
+	if err := logger.Init(logger.Options{
+		Env:                config.C.Env,
+		Level:              lc.Level,
+		Levels:             lc.Levels,
+		Format:             lc.Format,
+		File:               lc.File,
+		Stdout:             lc.Stdout,
+		MaxSize:            lc.MaxSize,
+		MaxBackups:         lc.MaxBackups,
+		MaxAge:             lc.MaxAge,
+		Compress:           lc.Compress,
+		Caller:             lc.Caller,
+		SamplingInitial:    lc.Sampling.Initial,
+		SamplingThereafter: lc.Sampling.Thereafter,
+	}); err != nil {
+		log.Fatalf("logger init failed: %v", err)
+	}
 	defer logger.Sync()
 
-	if config.C.Env == "prod" || config.C.Env == "production" {
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Options{
+		ServiceName: config.C.Tracing.ServiceName,
+		Exporter:    config.C.Tracing.Exporter,
+		Endpoint:    config.C.Tracing.Endpoint,
+		Insecure:    config.C.Tracing.Insecure,
+		SampleRatio: config.C.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("tracing init failed: %v", err)
+	}
+
+	if config.IsProd(config.C.Env) {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	ctx := context.Background()
-	clientInstance, err := app.Client(ctx)
-	if err != nil {
-		log.Fatalf("client app init failed: %v", err)
+	names := make([]string, 0, len(ports))
+	for _, p := range ports {
+		names = append(names, p.Name)
 	}
-	consoleInstance, err := app.Console(ctx)
-	if err != nil {
-		log.Fatalf("console app init failed: %v", err)
+	if err := app.Init(ctx, names); err != nil {
+		log.Fatalf("app init failed: %v", err)
 	}
 
-	if clientInstance.App.Postgres != nil {
-		repos.Setup(clientInstance.App.Postgres)
+	if pg := app.Postgres(); pg != nil {
+		repos.Setup(pg)
+		if sqlDB, err := pg.DB().DB(); err == nil {
+			if err := metrics.RegisterDB("postgres", sqlDB); err != nil {
+				log.Printf("warning: db metrics not registered: %v", err)
+			}
+		}
+		if config.C.DB.AutoMigrate {
+			runMigrations(ctx, pg.DB())
+		}
+		// console-managed firewall rules, polled so every replica applies them
+		go firewall.Sync(ctx, firewall.SourceDatabase, config.C.Firewall.RefreshInterval, fwsvc.New(pg.DB(), names).Load)
 	} else {
 		log.Printf("warning: postgres manager not initialized; repositories skipped")
 	}
 
-	clientAddr := overrideAddr(clientInstance.Project.IP(), clientInstance.Project.Port(), os.Getenv("PORT_CLIENT"))
-	consoleAddr := overrideAddr(consoleInstance.Project.IP(), consoleInstance.Project.Port(), os.Getenv("PORT_CONSOLE"))
+	closeLimiter := setupRateLimitStore(ctx, config.C.RateLimit)
+	go firewall.RunAutoban(ctx)
 
-	srv := server.NewServer()
+	srv, err := server.NewServer(ports...)
+	if err != nil {
+		log.Fatalf("server init failed: %v", err)
+	}
+	config.Watch()
 
 	go func() {
-		if err := srv.Start(clientAddr, consoleAddr); err != nil && err != http.ErrServerClosed {
+		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("server start error: %v", err)
 		}
 	}()
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if _, err := config.Reload(); err != nil {
+				log.Printf("config: reload rejected, keeping current config: %v", err)
+			}
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -64,19 +160,63 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("server shutdown error: %v", err)
 	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("tracing shutdown error: %v", err)
+	}
+	closeLimiter()
 }
 
-func overrideAddr(defaultIP, defaultPort, overridePort string) string {
-	ip := defaultIP
-	if ip == "" {
-		ip = "0.0.0.0"
+// setFlags collects repeatable -set key=value flags.
+type setFlags map[string]string
+
+func (s *setFlags) String() string { return fmt.Sprint(map[string]string(*s)) }
+
+func (s *setFlags) Set(v string) error {
+	key, value, ok := strings.Cut(v, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", v)
 	}
-	port := defaultPort
-	if overridePort != "" {
-		port = overridePort
+	if *s == nil {
+		*s = setFlags{}
 	}
-	if port == "" {
-		port = "8080"
+	(*s)[key] = value
+	return nil
+}
+
+// setupRateLimitStore points the rate limiters at the configured store and
+// returns its cleanup. An unreachable Redis at boot is not fatal: the
+// fallback limits locally until Redis answers.
+func setupRateLimitStore(ctx context.Context, cfg config.RateLimitConfig) func() {
+	local := ratelimit.NewMemory(cfg.MaxKeys)
+	if cfg.Store != "redis" {
+		middleware.SetLimiterStore(local)
+		return func() {}
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:         cfg.Redis.Addr,
+		Username:     cfg.Redis.Username,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		DialTimeout:  cfg.Redis.Timeout,
+		ReadTimeout:  cfg.Redis.Timeout,
+		WriteTimeout: cfg.Redis.Timeout,
+	})
+	store := ratelimit.NewRedis(client, cfg.Redis.Prefix)
+	if err := store.Ping(ctx); err != nil {
+		log.Printf("warning: rate limit redis %s unreachable, limiting locally until it is: %v", cfg.Redis.Addr, err)
+	}
+	middleware.SetLimiterStore(ratelimit.NewFallback(store, local))
+	return func() { _ = client.Close() }
+}
+
+func runMigrations(ctx context.Context, db *gorm.DB) {
+	m, err := migrations.New(db)
+	if err != nil {
+		log.Fatalf("migrations init failed: %v", err)
+	}
+	applied, err := m.Up(ctx)
+	if err != nil {
+		log.Fatalf("migrations failed: %v", err)
 	}
-	return fmt.Sprintf("%s:%s", ip, port)
+	log.Printf("boot: %d migration(s) applied", len(applied))
 }
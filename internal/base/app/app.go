@@ -3,12 +3,14 @@ package app
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/wiidz/gin_template/internal/base/config"
 
 	appMng "github.com/wiidz/goutil/mngs/appMng"
+	"github.com/wiidz/goutil/mngs/psqlMng"
 	"github.com/wiidz/goutil/structs/configStruct"
 )
 
@@ -42,66 +44,75 @@ func (p *HTTPProjectConfig) Port() string { return p.port }
 func (p *HTTPProjectConfig) Addr() string { return p.addr }
 
 var (
-	once        sync.Once
-	loadErr     error
-	clientInst  *Instance
-	consoleInst *Instance
+	once      sync.Once
+	loadErr   error
+	instances map[string]*Instance
 )
 
-func Init(ctx context.Context) error {
+// Init builds one app instance per port name. The first instance opens the
+// Postgres connection and the others share it.
+func Init(ctx context.Context, names []string) error {
 	once.Do(func() {
 		manager := appMng.DefaultManager()
-		debug := !isProd(config.C.Env)
-
-		clientLoader := baseLoader("client", debug)
-		clientInst = &Instance{Project: newHTTPProjectConfig("client")}
-		clientInst.App, loadErr = manager.Get(ctx, appMng.Options{
-			ID:            "client",
-			Loader:        clientLoader,
-			ProjectConfig: clientInst.Project,
-			CheckStart: &configStruct.CheckStart{
-				Postgres: config.C.DB.DSN != "",
-			},
-		})
-		if loadErr != nil {
-			return
-		}
-
-		consoleLoader := baseLoader("console", debug)
-		consoleInst = &Instance{Project: newHTTPProjectConfig("console")}
-		consoleInst.App, loadErr = manager.Get(ctx, appMng.Options{
-			ID:            "console",
-			Loader:        consoleLoader,
-			ProjectConfig: consoleInst.Project,
-			CheckStart:    &configStruct.CheckStart{},
-		})
-		if loadErr != nil {
-			return
+		debug := !config.IsProd(config.C.Env)
+		instances = make(map[string]*Instance, len(names))
+
+		var shared *Instance
+		for _, name := range names {
+			inst := &Instance{Project: newHTTPProjectConfig(name)}
+			inst.App, loadErr = manager.Get(ctx, appMng.Options{
+				ID:            name,
+				Loader:        baseLoader(name, debug),
+				ProjectConfig: inst.Project,
+				CheckStart: &configStruct.CheckStart{
+					Postgres: shared == nil && config.C.DB.DSN != "",
+				},
+			})
+			if loadErr != nil {
+				return
+			}
+			if shared == nil {
+				shared = inst
+			} else {
+				inst.App.Postgres = shared.App.Postgres
+			}
+			instances[name] = inst
 		}
-
-		consoleInst.App.Postgres = clientInst.App.Postgres
 	})
 	return loadErr
 }
 
-func Client(ctx context.Context) (*Instance, error) {
-	if ctx == nil {
-		ctx = context.Background()
+// Get returns the instance of a port passed to Init.
+func Get(name string) (*Instance, error) {
+	if loadErr != nil {
+		return nil, loadErr
 	}
-	if err := Init(ctx); err != nil {
-		return nil, err
+	inst, ok := instances[name]
+	if !ok {
+		return nil, fmt.Errorf("app: no instance for port %q", name)
 	}
-	return clientInst, nil
+	return inst, nil
 }
 
-func Console(ctx context.Context) (*Instance, error) {
-	if ctx == nil {
-		ctx = context.Background()
+// Names lists the ports passed to Init, sorted.
+func Names() []string {
+	names := make([]string, 0, len(instances))
+	for name := range instances {
+		names = append(names, name)
 	}
-	if err := Init(ctx); err != nil {
-		return nil, err
+	sort.Strings(names)
+	return names
+}
+
+// Postgres returns the connection shared by every instance, or nil when no
+// DSN is configured.
+func Postgres() *psqlMng.Manager {
+	for _, inst := range instances {
+		if inst.App.Postgres != nil {
+			return inst.App.Postgres
+		}
 	}
-	return consoleInst, nil
+	return nil
 }
 
 func baseLoader(name string, debug bool) appMng.Loader {
@@ -124,41 +135,10 @@ func baseLoader(name string, debug bool) appMng.Loader {
 }
 
 func resolveEndpoint(name string) (ip, port string) {
-	switch name {
-	case "client":
-		ip = config.C.HTTP2.Client.IP
-		port = config.C.HTTP2.Client.Port
-	case "console":
-		ip = config.C.HTTP2.Console.IP
-		port = config.C.HTTP2.Console.Port
-	default:
-		ip = config.C.HTTP.IP
-		port = config.C.HTTP.Port
-	}
-	if ip == "" {
-		ip = config.C.HTTP.IP
-	}
-	if port == "" {
-		if name == "console" {
-			port = "8081"
-		} else {
-			port = config.C.HTTP.Port
-		}
-	}
+	p := config.C.Ports[name]
+	ip, port = p.IP, p.Port
 	if ip == "" {
 		ip = "0.0.0.0"
 	}
-	if port == "" {
-		port = "8080"
-	}
 	return
 }
-
-func isProd(env string) bool {
-	switch env {
-	case "prod", "production":
-		return true
-	default:
-		return false
-	}
-}
@@ -2,63 +2,341 @@ package config
 
 import (
 	"log"
-	"strings"
-
-	"github.com/joho/godotenv"
-	"github.com/spf13/viper"
+	"net"
+	"time"
 )
 
-type HTTPConfig struct {
-	IP   string `mapstructure:"ip"`
-	Port string `mapstructure:"port"`
+// PortConfig is the listener of one HTTP surface registered with
+// server.Port under the same name. Zero timeouts keep the port's defaults.
+type PortConfig struct {
+	IP           string        `mapstructure:"ip" validate:"omitempty,ip"`
+	Port         string        `mapstructure:"port" validate:"required,port"`
+	Disabled     bool          `mapstructure:"disabled"`
+	ReadTimeout  time.Duration `mapstructure:"readTimeout" validate:"gte=0"`
+	WriteTimeout time.Duration `mapstructure:"writeTimeout" validate:"gte=0"`
+	// IdleTimeout closes idle keep-alive connections; ReadHeaderTimeout
+	// bounds reading the request headers (slowloris).
+	IdleTimeout       time.Duration `mapstructure:"idleTimeout" validate:"gte=0"`
+	ReadHeaderTimeout time.Duration `mapstructure:"readHeaderTimeout" validate:"gte=0"`
+	MaxHeaderBytes    int           `mapstructure:"maxHeaderBytes" validate:"gte=0"` // 0 = 1 MB
+	TLS               TLSConfig     `mapstructure:"tls"`
+	Limits            LimitsConfig  `mapstructure:"limits"`
+	// ErrorFormat is the default error body: "envelope" ({code,msg,data})
+	// or "problem" (RFC 7807). Clients may ask for problem+json via Accept.
+	ErrorFormat string `mapstructure:"errorFormat" validate:"omitempty,oneof=envelope problem"`
+	// Formats allows wire formats besides JSON for request and response
+	// bodies, negotiated via Content-Type and Accept.
+	Formats []string `mapstructure:"formats" validate:"dive,oneof=json msgpack protobuf xml"`
+	// TrustedProxies (IPs / CIDR blocks) may set X-Forwarded-For and
+	// X-Real-IP. Empty trusts none: the client IP seen by rate limits and
+	// the firewall is then the peer address.
+	TrustedProxies []string       `mapstructure:"trustedProxies" validate:"dive,ipnet"`
+	Security       SecurityConfig `mapstructure:"security"`
+}
+
+// LimitsConfig feeds middleware.BodyLimit and Timeout. Zero maxBodyBytes
+// keeps the port's default; zero timeout sets no deadline besides
+// writeTimeout.
+type LimitsConfig struct {
+	MaxBodyBytes int64         `mapstructure:"maxBodyBytes" validate:"gte=0"`
+	Timeout      time.Duration `mapstructure:"timeout" validate:"gte=0"`
+	// TimeoutStatus answers requests that ran out of time: 503 (default) or 504.
+	TimeoutStatus int `mapstructure:"timeoutStatus" validate:"omitempty,oneof=503 504"`
+	// LiftedTimeout bounds the connection of routes with a negative
+	// timeout (default 1h).
+	LiftedTimeout time.Duration `mapstructure:"liftedTimeout" validate:"gte=0"`
+	Routes        []RouteLimit  `mapstructure:"routes" validate:"dive"`
+}
+
+// RouteLimit overrides the port's limits for matching requests; the first
+// match wins. Zero values keep the port's limit, negative ones lift it
+// (e.g. uploads, streaming).
+type RouteLimit struct {
+	Path         string        `mapstructure:"path" validate:"required,startswith=/"` // route template; a trailing * matches a prefix
+	Methods      []string      `mapstructure:"methods" validate:"dive,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"`
+	MaxBodyBytes int64         `mapstructure:"maxBodyBytes"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+}
+
+// SecurityConfig feeds middleware.SecurityHeaders and HTTPSRedirect. Empty
+// headers keep their defaults; "off" omits one.
+type SecurityConfig struct {
+	HSTS HSTSConfig `mapstructure:"hsts"`
+	// CSP is the Content-Security-Policy; {nonce} is replaced by a fresh
+	// nonce per request (middleware.CSPNonce).
+	CSP                string `mapstructure:"csp"`
+	FrameOptions       string `mapstructure:"frameOptions" validate:"omitempty,oneof=DENY SAMEORIGIN off"`
+	ReferrerPolicy     string `mapstructure:"referrerPolicy"`
+	PermissionsPolicy  string `mapstructure:"permissionsPolicy"`
+	COOP               string `mapstructure:"coop" validate:"omitempty,oneof=same-origin same-origin-allow-popups unsafe-none off"`
+	COEP               string `mapstructure:"coep" validate:"omitempty,oneof=require-corp credentialless unsafe-none off"`
+	ContentTypeOptions string `mapstructure:"contentTypeOptions" validate:"omitempty,oneof=nosniff off"`
+	// RedirectHTTPS answers plain HTTP (per the TLS connection or a trusted
+	// proxy's X-Forwarded-Proto) with a 308 to https, on HTTPSPort if set,
+	// else 443.
+	RedirectHTTPS bool   `mapstructure:"redirectHTTPS"`
+	HTTPSPort     string `mapstructure:"httpsPort" validate:"omitempty,port"`
 }
 
-type HTTPMultiConfig struct {
-	Client  HTTPConfig `mapstructure:"client"`
-	Console HTTPConfig `mapstructure:"console"`
+// HSTSConfig is sent on HTTPS requests only; zero maxAge disables it.
+type HSTSConfig struct {
+	MaxAge            time.Duration `mapstructure:"maxAge" validate:"gte=0"`
+	IncludeSubdomains bool          `mapstructure:"includeSubdomains"`
+	Preload           bool          `mapstructure:"preload"`
+}
+
+// TLSConfig serves a port over HTTPS. Certificate files are watched and
+// reloaded on change. In dev, an enabled port without certFile/keyFile gets
+// a generated self-signed certificate.
+type TLSConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	CertFile     string   `mapstructure:"certFile"`
+	KeyFile      string   `mapstructure:"keyFile"`
+	MinVersion   string   `mapstructure:"minVersion" validate:"omitempty,oneof=1.2 1.3"`
+	CipherSuites []string `mapstructure:"cipherSuites" validate:"dive,ciphersuite"` // TLS 1.2 only; empty = Go defaults
+	// ClientCAFile turns on mutual TLS: client certificates must chain to it.
+	ClientCAFile string `mapstructure:"clientCAFile"`
+	// ClientAuth is "require" (default with a CA) or "optional".
+	ClientAuth string `mapstructure:"clientAuth" validate:"omitempty,oneof=require optional"`
+}
+
+// Addr returns ip:port, listening on every interface when ip is empty.
+func (p PortConfig) Addr() string {
+	ip := p.IP
+	if ip == "" {
+		ip = "0.0.0.0"
+	}
+	return net.JoinHostPort(ip, p.Port)
 }
 
 type DBConfig struct {
-	DSN         string `mapstructure:"dsn"`
+	DSN         string `mapstructure:"dsn" validate:"omitempty,dsn"`
 	AutoMigrate bool   `mapstructure:"autoMigrate"`
 }
 
+// LogConfig configures internal/common/logger. Level and Levels are
+// hot-reloadable; the output settings are read at boot. An empty level means
+// debug in dev, info elsewhere; an empty format console in dev, json
+// elsewhere.
+type LogConfig struct {
+	Level string `mapstructure:"level" validate:"omitempty,oneof=debug info warn error"`
+	// Levels overrides the level of named loggers, e.g. access: warn, repo: debug.
+	Levels map[string]string `mapstructure:"levels" validate:"dive,keys,required,endkeys,oneof=debug info warn error"`
+	Format string            `mapstructure:"format" validate:"omitempty,oneof=json console"`
+	File   string            `mapstructure:"file"`   // empty = stdout only
+	Stdout bool              `mapstructure:"stdout"` // also write to stdout when file is set
+
+	MaxSize    int  `mapstructure:"maxSize" validate:"gte=0"`    // MB per file before rotating
+	MaxBackups int  `mapstructure:"maxBackups" validate:"gte=0"` // rotated files kept
+	MaxAge     int  `mapstructure:"maxAge" validate:"gte=0"`     // days rotated files are kept
+	Compress   bool `mapstructure:"compress"`
+
+	Caller   bool           `mapstructure:"caller"`
+	Sampling SamplingConfig `mapstructure:"sampling"`
+}
+
+// SamplingConfig keeps the first Initial entries with the same level and
+// message per second, then every Thereafter-th. Zero values disable it.
+type SamplingConfig struct {
+	Initial    int `mapstructure:"initial" validate:"gte=0"`
+	Thereafter int `mapstructure:"thereafter" validate:"gte=0"`
+}
+
+type LimitConfig struct {
+	RPS   float64 `mapstructure:"rps" validate:"gt=0"`
+	Burst int     `mapstructure:"burst" validate:"gt=0"`
+}
+
+// RateLimitConfig: Global feeds middleware.RateLimit, IP feeds
+// middleware.RateLimitIP and Policies feed middleware.RateLimitPolicies, all
+// hot-reloadable. The store settings need a restart.
+type RateLimitConfig struct {
+	Global   LimitConfig       `mapstructure:"global"`
+	IP       LimitConfig       `mapstructure:"ip"`
+	Policies []RateLimitPolicy `mapstructure:"policies" validate:"dive"`
+
+	// Store is memory (per process) or redis (shared by replicas, falling
+	// back to memory while Redis is unreachable).
+	Store   string         `mapstructure:"store" validate:"oneof=memory redis"`
+	MaxKeys int            `mapstructure:"maxKeys" validate:"gte=0"` // in-memory buckets kept, LRU beyond
+	Redis   RateLimitRedis `mapstructure:"redis"`
+}
+
+// RateLimitPolicy adds a limit to the requests it matches; the first
+// matching policy applies. Empty match fields match everything.
+type RateLimitPolicy struct {
+	Name    string   `mapstructure:"name" validate:"required,excludes=:"`
+	Ports   []string `mapstructure:"ports"`
+	Path    string   `mapstructure:"path" validate:"omitempty,startswith=/"` // route template, e.g. /api/v1/users/:id; a trailing * matches a prefix
+	Methods []string `mapstructure:"methods" validate:"dive,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"`
+	Roles   []string `mapstructure:"roles"` // only logged-in callers with any of these roles
+	// Key is what gets its own bucket: ip, login_id, api_key (the header,
+	// X-API-Key by default), header (required) or global (one bucket).
+	Key    string  `mapstructure:"key" validate:"oneof=ip login_id api_key header global"`
+	Header string  `mapstructure:"header" validate:"required_if=Key header"`
+	RPS    float64 `mapstructure:"rps" validate:"gt=0"`
+	Burst  int     `mapstructure:"burst" validate:"gt=0"`
+	// The keys that get their own bucket; others are counted by IP.
+	APIKeyHashes []string `mapstructure:"apiKeyHashes" validate:"required_if=Key api_key,dive,len=64,hexadecimal"` // hex SHA-256 of each API key
+	Values       []string `mapstructure:"values" validate:"required_if=Key header"`                                // accepted header values
+}
+
+type RateLimitRedis struct {
+	Addr     string        `mapstructure:"addr"`
+	Username string        `mapstructure:"username"`
+	Password string        `mapstructure:"password"`
+	DB       int           `mapstructure:"db" validate:"gte=0"`
+	Prefix   string        `mapstructure:"prefix"`
+	Timeout  time.Duration `mapstructure:"timeout" validate:"gte=0"` // per command; keep it short
+}
+
+// CORSConfig is hot-reloadable. Its fields are the policy of ports without
+// an entry in Ports; an entry replaces it as a whole. No origins means any
+// origin; empty methods, headers and max age keep middleware.CORS's defaults.
+type CORSConfig struct {
+	// AllowOrigins lists exact origins, "*", or subdomain patterns such as
+	// https://*.example.com.
+	AllowOrigins     []string              `mapstructure:"allowOrigins" validate:"dive,origin"`
+	AllowMethods     []string              `mapstructure:"allowMethods" validate:"dive,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"`
+	AllowHeaders     []string              `mapstructure:"allowHeaders"`
+	ExposeHeaders    []string              `mapstructure:"exposeHeaders"`
+	AllowCredentials bool                  `mapstructure:"allowCredentials"`
+	MaxAge           time.Duration         `mapstructure:"maxAge" validate:"gte=0"`
+	Ports            map[string]CORSPolicy `mapstructure:"ports" validate:"dive"`
+}
+
+// Default returns the policy of ports without an entry in Ports.
+func (c CORSConfig) Default() CORSPolicy {
+	return CORSPolicy{
+		AllowOrigins:     c.AllowOrigins,
+		AllowMethods:     c.AllowMethods,
+		AllowHeaders:     c.AllowHeaders,
+		ExposeHeaders:    c.ExposeHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}
+}
+
+// CORSPolicy is the CORS setup of one port, with CORSConfig's fields.
+type CORSPolicy struct {
+	AllowOrigins     []string      `mapstructure:"allowOrigins" validate:"dive,origin"`
+	AllowMethods     []string      `mapstructure:"allowMethods" validate:"dive,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"`
+	AllowHeaders     []string      `mapstructure:"allowHeaders"`
+	ExposeHeaders    []string      `mapstructure:"exposeHeaders"`
+	AllowCredentials bool          `mapstructure:"allowCredentials"`
+	MaxAge           time.Duration `mapstructure:"maxAge" validate:"gte=0"`
+}
+
+// DenylistConfig is hot-reloadable; IPs (or CIDR blocks) are denied on
+// every port.
+type DenylistConfig struct {
+	IPs []string `mapstructure:"ips" validate:"dive,ipnet"`
+}
+
+// FirewallConfig: Allow is hot-reloadable. Rules added from the console are
+// stored in the database and reloaded every RefreshInterval.
+type FirewallConfig struct {
+	// Allow restricts ports to IPs / CIDR blocks, e.g. console: [10.0.0.0/8].
+	Allow           map[string][]string `mapstructure:"allow" validate:"dive,keys,required,endkeys,dive,ipnet"`
+	RefreshInterval time.Duration       `mapstructure:"refreshInterval" validate:"gt=0"`
+}
+
+// MetricsConfig guards the console's /metrics and is hot-reloadable. With
+// neither set, nobody can scrape.
+type MetricsConfig struct {
+	Allow       []string `mapstructure:"allow" validate:"dive,ipnet"` // scraper IPs / CIDR blocks
+	BearerToken string   `mapstructure:"bearerToken"`                 // also lets in "Authorization: Bearer <token>"
+}
+
+// AutobanConfig is hot-reloadable and feeds firewall.SetBanPolicy; bans in
+// force are kept across reloads.
+type AutobanConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	BanTime     time.Duration `mapstructure:"banTime" validate:"gt=0"`                // first ban
+	MaxBanTime  time.Duration `mapstructure:"maxBanTime" validate:"gtefield=BanTime"` // each repeat doubles, up to this
+	ForgetAfter time.Duration `mapstructure:"forgetAfter" validate:"gt=0"`            // clean time before a client starts over
+	Ignore      []string      `mapstructure:"ignore" validate:"dive,ipnet"`           // never banned
+	Rules       []AutobanRule `mapstructure:"rules" validate:"dive"`
+}
+
+// AutobanRule bans a client after MaxRetry responses with one of Status
+// within FindTime on matching requests.
+type AutobanRule struct {
+	Name     string        `mapstructure:"name" validate:"required"`
+	Status   []int         `mapstructure:"status" validate:"required,dive,gte=400,lte=599"`
+	Ports    []string      `mapstructure:"ports"`
+	Path     string        `mapstructure:"path" validate:"omitempty,startswith=/"` // route template; a trailing * matches a prefix
+	MaxRetry int           `mapstructure:"maxRetry" validate:"gt=0"`
+	FindTime time.Duration `mapstructure:"findTime" validate:"gt=0"`
+}
+
+// TracingConfig selects the OpenTelemetry span exporter. Endpoint is the
+// OTLP/HTTP collector host:port; empty falls back to OTEL_EXPORTER_OTLP_*.
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"exporter" validate:"oneof=none otlp stdout memory"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	SampleRatio float64 `mapstructure:"sampleRatio" validate:"gte=0,lte=1"`
+	ServiceName string  `mapstructure:"serviceName" validate:"required"`
+}
+
 type AppConfig struct {
-	Env   string          `mapstructure:"env"`
-	HTTP  HTTPConfig      `mapstructure:"http"`
-	HTTP2 HTTPMultiConfig `mapstructure:"http2"`
-	DB    DBConfig        `mapstructure:"db"`
+	Env       string                `mapstructure:"env" validate:"required,oneof=dev development test staging prod production"`
+	Ports     map[string]PortConfig `mapstructure:"ports" validate:"required,dive"`
+	DB        DBConfig              `mapstructure:"db"`
+	Log       LogConfig             `mapstructure:"log"`
+	RateLimit RateLimitConfig       `mapstructure:"rateLimit"`
+	CORS      CORSConfig            `mapstructure:"cors"`
+	Denylist  DenylistConfig        `mapstructure:"denylist"`
+	Firewall  FirewallConfig        `mapstructure:"firewall"`
+	Autoban   AutobanConfig         `mapstructure:"autoban"`
+	Metrics   MetricsConfig         `mapstructure:"metrics"`
+	Tracing   TracingConfig         `mapstructure:"tracing"`
 }
 
+// C is the config loaded at boot. It is never updated by a reload, so read
+// hot-reloadable keys through Current or Subscribe instead.
 var C AppConfig
 
-func Init() {
-	// Load .env if present
-	_ = godotenv.Load()
-
-	viper.SetConfigName("config")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("./configs")
-	viper.SetConfigType("yaml")
-
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	viper.AutomaticEnv()
-
-	viper.SetDefault("env", "dev")
-	viper.SetDefault("http.ip", "0.0.0.0")
-	viper.SetDefault("http.port", "8080")
-	viper.SetDefault("http2.client.ip", "0.0.0.0")
-	viper.SetDefault("http2.client.port", "8080")
-	viper.SetDefault("http2.console.ip", "0.0.0.0")
-	viper.SetDefault("http2.console.port", "8081")
-	viper.SetDefault("db.dsn", "")
-	viper.SetDefault("db.autoMigrate", false)
-
-	if err := viper.ReadInConfig(); err != nil {
-		// optional
-		log.Printf("config: using defaults/env, no config file: %v", err)
+// Init loads the config into C and exits on any decode or validation problem.
+func Init(opts Options) {
+	cfg, eff, err := Load(opts)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	C = cfg
+	store(opts, &cfg, eff)
+}
+
+// Load merges the config layers (see resolve) and validates the result.
+// Validation failures are returned as a *ValidationError; the merged view is
+// returned whenever the layers could be read, so callers can still print it.
+func Load(opts Options) (AppConfig, *Effective, error) {
+	eff, err := resolve(opts)
+	if err != nil {
+		return AppConfig{}, nil, err
+	}
+	if len(eff.Files) == 0 {
+		log.Printf("config: using defaults/env, no config file found")
+	}
+	for _, w := range eff.Deprecated {
+		log.Printf("config: %s", w)
+	}
+	cfg, err := eff.decode()
+	if err != nil {
+		return cfg, eff, err
+	}
+	err = cfg.Validate()
+	if len(eff.removed) > 0 {
+		ve, _ := err.(*ValidationError)
+		if ve == nil {
+			ve = &ValidationError{}
+		}
+		ve.Problems = append(append([]Problem(nil), eff.removed...), ve.Problems...)
+		err = ve
 	}
-	if err := viper.Unmarshal(&C); err != nil {
-		log.Fatalf("config unmarshal error: %v", err)
+	if err != nil {
+		return cfg, eff, err
 	}
+	return cfg, eff, nil
 }
@@ -0,0 +1,405 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/viper"
+)
+
+// Options selects the profile and carries command-line overrides. The zero
+// value loads config.yaml from . or ./configs with no overrides.
+type Options struct {
+	Env       string            // profile name; beats env from every other layer (--env)
+	Dir       string            // directory holding config.yaml (--config-dir)
+	Overrides map[string]string // dotted key -> value from --set, highest precedence
+}
+
+// Layer names recorded as the source of each effective key.
+const (
+	LayerDefault = "default"
+	LayerDotenv  = ".env"
+	LayerEnv     = "env"
+	LayerFlag    = "flag"
+)
+
+var defaults = map[string]any{
+	"env":                      "dev",
+	"ports.client.ip":          "0.0.0.0",
+	"ports.client.port":        "8080",
+	"ports.console.ip":         "0.0.0.0",
+	"ports.console.port":       "8082",
+	"db.dsn":                   "",
+	"db.autoMigrate":           false,
+	"log.level":                "",
+	"log.format":               "",
+	"log.file":                 "",
+	"log.stdout":               false,
+	"log.maxSize":              100,
+	"log.maxBackups":           7,
+	"log.maxAge":               28,
+	"log.compress":             true,
+	"log.caller":               false,
+	"log.sampling.initial":     0,
+	"log.sampling.thereafter":  0,
+	"rateLimit.global.rps":     100,
+	"rateLimit.global.burst":   200,
+	"rateLimit.ip.rps":         50,
+	"rateLimit.ip.burst":       100,
+	"rateLimit.policies":       []any{},
+	"rateLimit.store":          "memory",
+	"rateLimit.maxKeys":        100000,
+	"rateLimit.redis.addr":     "",
+	"rateLimit.redis.username": "",
+	"rateLimit.redis.password": "",
+	"rateLimit.redis.db":       0,
+	"rateLimit.redis.prefix":   "ratelimit:",
+	"rateLimit.redis.timeout":  "100ms",
+	"cors.allowOrigins":        []string{},
+	"cors.allowMethods":        []string{},
+	"cors.allowHeaders":        []string{},
+	"cors.exposeHeaders":       []string{},
+	"cors.allowCredentials":    false,
+	"cors.maxAge":              "0s",
+	"denylist.ips":             []string{},
+	"firewall.refreshInterval": "10s",
+	"autoban.enabled":          false,
+	"autoban.banTime":          "10m",
+	"autoban.maxBanTime":       "24h",
+	"autoban.forgetAfter":      "24h",
+	"autoban.ignore":           []string{},
+	"autoban.rules":            []any{},
+	"metrics.allow":            []string{"127.0.0.1", "::1"},
+	"metrics.bearerToken":      "",
+	"tracing.exporter":         "none",
+	"tracing.endpoint":         "",
+	"tracing.insecure":         false,
+	"tracing.sampleRatio":      1.0,
+	"tracing.serviceName":      "gin_template",
+}
+
+// legacyKeys are keys from before ports.<name> that still load, each into
+// the key that replaced it, below that key within the same layer.
+var legacyKeys = map[string]string{
+	"http2.client.ip":    "ports.client.ip",
+	"http2.client.port":  "ports.client.port",
+	"http2.console.ip":   "ports.console.ip",
+	"http2.console.port": "ports.console.port",
+}
+
+// legacyEnv are environment variables that still set a key, lowest first.
+var legacyEnv = map[string][]string{
+	"ports.client.ip":    {"HTTP2_CLIENT_IP"},
+	"ports.client.port":  {"HTTP2_CLIENT_PORT", "PORT_CLIENT"},
+	"ports.console.ip":   {"HTTP2_CONSOLE_IP"},
+	"ports.console.port": {"HTTP2_CONSOLE_PORT", "PORT_CONSOLE"},
+}
+
+// removedSections are config file sections that are no longer read, with
+// their replacement. Setting one fails validation.
+var removedSections = map[string]string{
+	"http": "ports.<name>",
+}
+
+// secretHints mark keys whose values are redacted when printed.
+var secretHints = []string{"dsn", "password", "secret", "token", "apikey", "privatekey"}
+
+// Effective is the merged config as flat dotted keys with the layer each
+// value came from.
+type Effective struct {
+	values  map[string]any
+	sources map[string]string
+	names   map[string]string // lower-case key -> canonical spelling
+	Files   []string          // config files read, in order
+
+	Deprecated []string  // legacy keys that were read, as warnings
+	removed    []Problem // keys of removedSections that were set
+}
+
+// Source returns the layer key was taken from, e.g. "file:configs/config.prod.yaml".
+func (e *Effective) Source(key string) string { return e.sources[strings.ToLower(key)] }
+
+// Write dumps every key as `key: value  # layer`, redacting secrets.
+func (e *Effective) Write(w io.Writer) error {
+	keys := make([]string, 0, len(e.values))
+	for k := range e.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := e.name(k)
+		v := e.values[k]
+		if isSecret(k) && fmt.Sprint(v) != "" {
+			v = "******"
+		}
+		if _, err := fmt.Fprintf(w, "%s: %v  # %s\n", name, formatValue(v), e.sources[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name returns the canonical spelling of a lower-case key. Keys inside maps
+// such as ports.<name>.readTimeout match the schema's "*" segment.
+func (e *Effective) name(key string) string {
+	if n := e.names[key]; n != "" {
+		return n
+	}
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = "*"
+		if n := e.names[strings.Join(parts, ".")]; n != "" {
+			out := strings.Split(n, ".")
+			out[i] = p
+			return strings.Join(out, ".")
+		}
+		parts[i] = p
+	}
+	return key
+}
+
+func formatValue(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func isSecret(key string) bool {
+	last := key[strings.LastIndex(key, ".")+1:]
+	for _, hint := range secretHints {
+		if strings.Contains(last, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	envOnce    sync.Once
+	processEnv map[string]string
+)
+
+// realEnv snapshots the process environment before .env is exported, so the
+// two layers can be told apart on every later load (e.g. hot reload).
+func realEnv() map[string]string {
+	envOnce.Do(func() {
+		processEnv = make(map[string]string)
+		for _, kv := range os.Environ() {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				processEnv[k] = v
+			}
+		}
+		// keep .env visible to code reading os.Getenv directly
+		_ = godotenv.Load()
+	})
+	return processEnv
+}
+
+// resolve merges defaults < config.yaml < config.<env>.yaml < .env <
+// environment < overrides into flat keys.
+func resolve(opts Options) (*Effective, error) {
+	eff := &Effective{
+		values:  make(map[string]any),
+		sources: make(map[string]string),
+		names:   make(map[string]string),
+	}
+	for _, k := range schemaKeys(reflect.TypeOf(AppConfig{}), "") {
+		eff.names[strings.ToLower(k)] = k
+	}
+	set := func(key string, v any, source string) {
+		k := strings.ToLower(key)
+		eff.values[k] = v
+		eff.sources[k] = source
+	}
+
+	for k, v := range defaults {
+		set(k, v, LayerDefault)
+	}
+
+	dir, err := configDir(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := eff.readFile(filepath.Join(dir, "config.yaml"), set); err != nil {
+			return nil, err
+		}
+	}
+
+	dotenv, err := readDotenv()
+	if err != nil {
+		return nil, err
+	}
+	environ := realEnv()
+
+	// The profile depends on env, which any layer but the profile file may set.
+	env := fmt.Sprint(eff.values["env"])
+	for _, layer := range []map[string]string{dotenv, environ} {
+		if v, ok := layer[envVar("env")]; ok && v != "" {
+			env = v
+		}
+	}
+	if v, ok := opts.Overrides["env"]; ok {
+		env = v
+	}
+	if opts.Env != "" {
+		env = opts.Env
+	}
+	if dir != "" && env != "" {
+		if err := eff.readFile(filepath.Join(dir, "config."+env+".yaml"), set); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]string, 0, len(eff.names)+len(eff.values))
+	for k := range eff.names {
+		if !strings.Contains(k, "*") {
+			keys = append(keys, k)
+		}
+	}
+	for k := range eff.values {
+		if _, ok := eff.names[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		name := envVar(k)
+		for _, layer := range []struct {
+			name string
+			vars map[string]string
+		}{{LayerDotenv, dotenv}, {LayerEnv, environ}} {
+			for _, old := range legacyEnv[k] {
+				if v, ok := layer.vars[old]; ok {
+					eff.deprecate(old, name, layer.name)
+					set(k, v, layer.name+":"+old)
+				}
+			}
+			if v, ok := layer.vars[name]; ok {
+				set(k, v, layer.name+":"+name)
+			}
+		}
+	}
+	for k, v := range opts.Overrides {
+		set(k, v, LayerFlag+":--set "+k)
+	}
+	if opts.Env != "" {
+		set("env", opts.Env, LayerFlag+":--env")
+	}
+	return eff, nil
+}
+
+// decode unmarshals the flat keys into AppConfig with viper's weak typing.
+func (e *Effective) decode() (AppConfig, error) {
+	v := viper.New()
+	for k, val := range e.values {
+		v.Set(k, val)
+	}
+	var cfg AppConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return cfg, fmt.Errorf("unmarshal: %w", err)
+	}
+	return cfg, nil
+}
+
+func (e *Effective) readFile(path string, set func(string, any, string)) error {
+	fv := viper.New()
+	fv.SetConfigFile(path)
+	fv.SetConfigType("yaml")
+	if err := fv.ReadInConfig(); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	e.Files = append(e.Files, path)
+	source := "file:" + filepath.ToSlash(path)
+	keys := fv.AllKeys()
+	// legacy keys first, so their replacement in the same file wins
+	sort.SliceStable(keys, func(i, j int) bool {
+		_, li := legacyKeys[keys[i]]
+		_, lj := legacyKeys[keys[j]]
+		return li && !lj
+	})
+	for _, k := range keys {
+		if to, ok := legacyKeys[k]; ok {
+			e.deprecate(k, to, source)
+			set(to, fv.Get(k), source)
+			continue
+		}
+		section, rest, _ := strings.Cut(k, ".")
+		if to, ok := removedSections[section]; ok {
+			e.removed = append(e.removed, Problem{Key: k, Message: fmt.Sprintf("%s is no longer read, use %s.%s (%s)", section, to, rest, source)})
+			continue
+		}
+		set(k, fv.Get(k), source)
+	}
+	return nil
+}
+
+func (e *Effective) deprecate(old, replacement, source string) {
+	e.Deprecated = append(e.Deprecated, fmt.Sprintf("%s (%s) is deprecated, use %s", old, source, replacement))
+}
+
+func configDir(dir string) (string, error) {
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return "", fmt.Errorf("config dir: %w", err)
+		}
+		return dir, nil
+	}
+	for _, candidate := range []string{".", "./configs"} {
+		if _, err := os.Stat(filepath.Join(candidate, "config.yaml")); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", nil
+}
+
+func readDotenv() (map[string]string, error) {
+	m, err := godotenv.Read()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("read .env: %w", err)
+	}
+	return m, nil
+}
+
+// envVar maps a dotted key to its environment variable, e.g. db.dsn -> DB_DSN.
+func envVar(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// schemaKeys lists the dotted keys of every leaf field in t.
+func schemaKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+		switch {
+		case f.Type.Kind() == reflect.Struct:
+			keys = append(keys, schemaKeys(f.Type, key+".")...)
+			continue
+		case f.Type.Kind() == reflect.Map && f.Type.Elem().Kind() == reflect.Struct:
+			keys = append(keys, schemaKeys(f.Type.Elem(), key+".*.")...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
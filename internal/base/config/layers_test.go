@@ -0,0 +1,272 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// layers is one setup of every config layer; empty ones are left out.
+type layers struct {
+	files  map[string]string // file name in the config dir -> contents
+	dotenv string
+	env    map[string]string
+	opts   Options
+}
+
+// resolveLayers writes the layers into a temporary working directory and
+// resolves them.
+func resolveLayers(t *testing.T, l layers) *Effective {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for name, body := range l.files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l.dotenv != "" {
+		if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(l.dotenv), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// realEnv exports .env into the process; have t restore it afterwards
+		for _, line := range strings.Split(l.dotenv, "\n") {
+			if k, _, ok := strings.Cut(line, "="); ok {
+				t.Setenv(k, "")
+				_ = os.Unsetenv(k)
+			}
+		}
+	}
+	for k, v := range l.env {
+		t.Setenv(k, v)
+	}
+	envOnce, processEnv = sync.Once{}, nil
+	t.Cleanup(func() { envOnce, processEnv = sync.Once{}, nil })
+
+	opts := l.opts
+	opts.Dir = dir
+	eff, err := resolve(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return eff
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	base := "db:\n  dsn: file\n"
+	profile := "db:\n  dsn: profile\n"
+	tests := []struct {
+		name       string
+		layers     layers
+		want       string
+		wantSource string
+	}{
+		{
+			name:       "default",
+			want:       "",
+			wantSource: LayerDefault,
+		},
+		{
+			name:       "config.yaml over default",
+			layers:     layers{files: map[string]string{"config.yaml": base}},
+			want:       "file",
+			wantSource: "file:*/config.yaml",
+		},
+		{
+			name: "profile over config.yaml",
+			layers: layers{files: map[string]string{
+				"config.yaml":      "env: prod\n" + base,
+				"config.prod.yaml": profile,
+			}},
+			want:       "profile",
+			wantSource: "file:*/config.prod.yaml",
+		},
+		{
+			name: ".env over profile",
+			layers: layers{
+				files:  map[string]string{"config.yaml": "env: prod\n" + base, "config.prod.yaml": profile},
+				dotenv: "DB_DSN=dotenv",
+			},
+			want:       "dotenv",
+			wantSource: LayerDotenv + ":DB_DSN",
+		},
+		{
+			name: "environment over .env",
+			layers: layers{
+				files:  map[string]string{"config.yaml": base},
+				dotenv: "DB_DSN=dotenv",
+				env:    map[string]string{"DB_DSN": "environ"},
+			},
+			want:       "environ",
+			wantSource: LayerEnv + ":DB_DSN",
+		},
+		{
+			name: "--set over environment",
+			layers: layers{
+				files: map[string]string{"config.yaml": base},
+				env:   map[string]string{"DB_DSN": "environ"},
+				opts:  Options{Overrides: map[string]string{"db.dsn": "flag"}},
+			},
+			want:       "flag",
+			wantSource: LayerFlag + ":--set db.dsn",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eff := resolveLayers(t, tt.layers)
+			if got := eff.values["db.dsn"]; got != tt.want {
+				t.Errorf("db.dsn = %v, want %q", got, tt.want)
+			}
+			source := eff.Source("db.dsn")
+			if prefix, suffix, wild := strings.Cut(tt.wantSource, "*"); wild {
+				if !strings.HasPrefix(source, prefix) || !strings.HasSuffix(source, suffix) {
+					t.Errorf("source = %q, want %q", source, tt.wantSource)
+				}
+			} else if source != tt.wantSource {
+				t.Errorf("source = %q, want %q", source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestResolveProfileSelection(t *testing.T) {
+	files := map[string]string{
+		"config.yaml":         "env: staging\n",
+		"config.staging.yaml": "db:\n  dsn: staging\n",
+		"config.prod.yaml":    "db:\n  dsn: prod\n",
+		"config.test.yaml":    "db:\n  dsn: test\n",
+	}
+	tests := []struct {
+		name   string
+		layers layers
+		want   string
+	}{
+		{"config.yaml", layers{}, "staging"},
+		{".env", layers{dotenv: "ENV=prod"}, "prod"},
+		{"environment over .env", layers{dotenv: "ENV=test", env: map[string]string{"ENV": "prod"}}, "prod"},
+		{"--set env", layers{env: map[string]string{"ENV": "test"}, opts: Options{Overrides: map[string]string{"env": "prod"}}}, "prod"},
+		{"--env over --set env", layers{opts: Options{Env: "test", Overrides: map[string]string{"env": "prod"}}}, "test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.layers.files = files
+			eff := resolveLayers(t, tt.layers)
+			if got := eff.values["db.dsn"]; got != tt.want {
+				t.Errorf("db.dsn = %v, want %q (profile not picked)", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveWriteRedactsSecrets(t *testing.T) {
+	eff := resolveLayers(t, layers{files: map[string]string{
+		"config.yaml": "db:\n  dsn: postgres://u:p@h/db\nports:\n  client:\n    readTimeout: 5s\n",
+	}})
+	var b strings.Builder
+	if err := eff.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if strings.Contains(out, "u:p@h") || !strings.Contains(out, `db.dsn: "******"`) {
+		t.Errorf("dsn not redacted:\n%s", out)
+	}
+	// keys inside the ports map keep the schema's spelling
+	if !strings.Contains(out, "ports.client.readTimeout: \"5s\"") {
+		t.Errorf("ports.client.readTimeout missing or misspelled:\n%s", out)
+	}
+}
+
+func TestResolveLegacyPortKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		layers layers
+		key    string
+		want   string
+	}{
+		{
+			name:   "http2 section",
+			layers: layers{files: map[string]string{"config.yaml": "http2:\n  console:\n    ip: 127.0.0.1\n    port: \"9000\"\n"}},
+			key:    "ports.console.port",
+			want:   "9000",
+		},
+		{
+			name:   "http2 section ip",
+			layers: layers{files: map[string]string{"config.yaml": "http2:\n  console:\n    ip: 127.0.0.1\n"}},
+			key:    "ports.console.ip",
+			want:   "127.0.0.1",
+		},
+		{
+			name:   "ports beats http2 in the same file",
+			layers: layers{files: map[string]string{"config.yaml": "http2:\n  client:\n    port: \"9000\"\nports:\n  client:\n    port: \"9001\"\n"}},
+			key:    "ports.client.port",
+			want:   "9001",
+		},
+		{
+			name:   "http2 in the profile beats ports in config.yaml",
+			layers: layers{files: map[string]string{"config.yaml": "env: prod\nports:\n  client:\n    port: \"9001\"\n", "config.prod.yaml": "http2:\n  client:\n    port: \"9000\"\n"}},
+			key:    "ports.client.port",
+			want:   "9000",
+		},
+		{
+			name:   "PORT_CLIENT",
+			layers: layers{env: map[string]string{"PORT_CLIENT": "9100"}},
+			key:    "ports.client.port",
+			want:   "9100",
+		},
+		{
+			name:   "PORT_CONSOLE over HTTP2_CONSOLE_PORT",
+			layers: layers{env: map[string]string{"PORT_CONSOLE": "9200", "HTTP2_CONSOLE_PORT": "9300"}},
+			key:    "ports.console.port",
+			want:   "9200",
+		},
+		{
+			name:   "PORTS_CLIENT_PORT over PORT_CLIENT",
+			layers: layers{env: map[string]string{"PORT_CLIENT": "9100", "PORTS_CLIENT_PORT": "9101"}},
+			key:    "ports.client.port",
+			want:   "9101",
+		},
+		{
+			name:   "PORT_CLIENT over .env",
+			layers: layers{dotenv: "PORTS_CLIENT_PORT=9101", env: map[string]string{"PORT_CLIENT": "9100"}},
+			key:    "ports.client.port",
+			want:   "9100",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eff := resolveLayers(t, tt.layers)
+			if got := eff.values[tt.key]; got != tt.want {
+				t.Errorf("%s = %v, want %q", tt.key, got, tt.want)
+			}
+			if _, ok := eff.values["http2.client.port"]; ok {
+				t.Error("legacy key kept next to its replacement")
+			}
+			deprecated := strings.Contains(tt.name, "http2") || strings.Contains(tt.name, "PORT_")
+			if deprecated && len(eff.Deprecated) == 0 {
+				t.Error("no deprecation warning")
+			}
+		})
+	}
+}
+
+func TestLoadRejectsRemovedHTTPSection(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("http:\n  port: \"8081\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	envOnce, processEnv = sync.Once{}, nil
+	t.Cleanup(func() { envOnce, processEnv = sync.Once{}, nil })
+
+	_, _, err := Load(Options{Dir: dir})
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	if ve.Problems[0].Key != "http.port" || !strings.Contains(ve.Problems[0].Message, "ports.<name>.port") {
+		t.Fatalf("problem = %+v, want http.port pointing at ports.<name>.port", ve.Problems[0])
+	}
+}
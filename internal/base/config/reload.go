@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// reloadable lists the key prefixes a running server can pick up. Changes to
+// any other key are logged as requiring a restart and are not applied.
+// "log.level" also covers log.levels.*.
+var reloadable = []string{"log.level", "ratelimit.global.", "ratelimit.ip.", "ratelimit.policies", "cors.", "denylist.", "firewall.allow", "autoban.", "metrics."}
+
+// Change describes an accepted reload.
+type Change struct {
+	Old, New *AppConfig
+	Keys     []string // applied keys whose value changed
+	Restart  []string // changed keys that only take effect after a restart
+}
+
+// Changed reports whether any applied key under prefix changed, e.g.
+// Changed("rateLimit.").
+func (c Change) Changed(prefix string) bool {
+	prefix = strings.ToLower(prefix)
+	for _, k := range c.Keys {
+		if strings.HasPrefix(strings.ToLower(k), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	current atomic.Pointer[AppConfig]
+
+	// reloadMu serialises reloads and guards the state below.
+	reloadMu    sync.Mutex
+	loadedOpts  Options
+	loadedEff   *Effective
+	subscribers []func(Change)
+)
+
+func store(opts Options, cfg *AppConfig, eff *Effective) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	loadedOpts = opts
+	loadedEff = eff
+	current.Store(cfg)
+}
+
+// Current returns the latest accepted config snapshot. Callers must not
+// modify it.
+func Current() *AppConfig {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+	return &C
+}
+
+// Subscribe registers fn to run after every reload that changed a key.
+// Subscribers run in registration order on the reloading goroutine.
+func Subscribe(fn func(Change)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+// Reload re-reads every layer with the options given to Init. An invalid
+// config is rejected and the current snapshot kept; otherwise reloadable
+// keys are swapped in atomically and subscribers are notified.
+func Reload() (Change, error) {
+	reloadMu.Lock()
+	old := Current()
+	cfg, eff, err := Load(loadedOpts)
+	if err != nil {
+		reloadMu.Unlock()
+		return Change{}, err
+	}
+
+	next := *old
+	next.Log.Level = cfg.Log.Level
+	next.Log.Levels = cfg.Log.Levels
+	next.RateLimit.Global = cfg.RateLimit.Global
+	next.RateLimit.IP = cfg.RateLimit.IP
+	next.RateLimit.Policies = cfg.RateLimit.Policies
+	next.CORS = cfg.CORS
+	next.Denylist = cfg.Denylist
+	next.Firewall.Allow = cfg.Firewall.Allow
+	next.Autoban = cfg.Autoban
+	next.Metrics = cfg.Metrics
+
+	ch := Change{Old: old, New: &next}
+	var restart []string
+	for _, k := range diffKeys(loadedEff, eff) {
+		if isReloadable(k) {
+			ch.Keys = append(ch.Keys, eff.name(k))
+		} else {
+			ch.Restart = append(ch.Restart, eff.name(k))
+			restart = append(restart, k)
+		}
+	}
+	// Restart-only keys stay as they were, so keep comparing against the
+	// running values the next time round.
+	loadedEff = eff.withValues(loadedEff, restart)
+	current.Store(&next)
+	subs := append([]func(Change){}, subscribers...)
+	reloadMu.Unlock()
+
+	for _, k := range ch.Restart {
+		log.Printf("config: %s changed, requires restart", k)
+	}
+	if len(ch.Keys) > 0 {
+		log.Printf("config: reloaded %s", strings.Join(ch.Keys, ", "))
+		for _, fn := range subs {
+			fn(ch)
+		}
+	}
+	return ch, nil
+}
+
+// Watch reloads whenever a config file read by Init is written. Bursts of
+// events from a single save are coalesced.
+func Watch() {
+	reloadMu.Lock()
+	var files []string
+	if loadedEff != nil {
+		files = append(files, loadedEff.Files...)
+	}
+	reloadMu.Unlock()
+	if len(files) == 0 {
+		log.Printf("config: no config file to watch")
+		return
+	}
+
+	var (
+		mu    sync.Mutex
+		timer *time.Timer
+	)
+	onChange := func(fsnotify.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(200*time.Millisecond, func() {
+			if _, err := Reload(); err != nil {
+				log.Printf("config: reload rejected, keeping current config: %v", err)
+			}
+		})
+	}
+	for _, f := range files {
+		v := viper.New()
+		v.SetConfigFile(f)
+		v.SetConfigType("yaml")
+		v.OnConfigChange(onChange)
+		v.WatchConfig()
+		log.Printf("config: watching %s", f)
+	}
+}
+
+func isReloadable(key string) bool {
+	for _, p := range reloadable {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffKeys lists the lower-case keys whose value differs between a and b.
+func diffKeys(a, b *Effective) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	for _, e := range []*Effective{a, b} {
+		for k := range e.values {
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+			if fmt.Sprint(a.values[k]) != fmt.Sprint(b.values[k]) {
+				out = append(out, k)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// withValues returns e with keys copied back from prev.
+func (e *Effective) withValues(prev *Effective, keys []string) *Effective {
+	for _, k := range keys {
+		if v, ok := prev.values[k]; ok {
+			e.values[k] = v
+			e.sources[k] = prev.sources[k]
+		} else {
+			delete(e.values, k)
+			delete(e.sources, k)
+		}
+	}
+	return e
+}
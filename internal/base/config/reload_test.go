@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+const reloadBase = `
+ports:
+  client: {port: "8081"}
+log:
+  level: info
+rateLimit:
+  global: {rps: 10, burst: 20}
+`
+
+// initReload loads config.yaml with body like Init and returns a function
+// rewriting it. The loaded state and subscribers are reset afterwards.
+func initReload(t *testing.T, body string) func(string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	write := func(body string) {
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(body)
+	envOnce, processEnv = sync.Once{}, nil
+	t.Cleanup(func() {
+		envOnce, processEnv = sync.Once{}, nil
+		reloadMu.Lock()
+		loadedOpts, loadedEff, subscribers = Options{}, nil, nil
+		reloadMu.Unlock()
+		current.Store(nil)
+		C = AppConfig{}
+	})
+	Init(Options{Dir: dir})
+	return write
+}
+
+func TestReloadNotifiesSubscribers(t *testing.T) {
+	write := initReload(t, reloadBase)
+	var changes []Change
+	Subscribe(func(ch Change) { changes = append(changes, ch) })
+
+	write(`
+ports:
+  client: {port: "9091"}
+log:
+  level: warn
+rateLimit:
+  global: {rps: 20, burst: 20}
+`)
+	ch, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"log.level", "rateLimit.global.rps"}; !slices.Equal(ch.Keys, want) {
+		t.Fatalf("keys = %q, want %q", ch.Keys, want)
+	}
+	if want := []string{"ports.client.port"}; !slices.Equal(ch.Restart, want) {
+		t.Fatalf("restart = %q, want %q", ch.Restart, want)
+	}
+	if !ch.Changed("ratelimit.") || ch.Changed("ports.") || ch.Changed("cors.") {
+		t.Fatalf("Changed does not follow keys %q", ch.Keys)
+	}
+	if len(changes) != 1 || changes[0].New != Current() || changes[0].Old.RateLimit.Global.RPS != 10 {
+		t.Fatalf("subscriber got %+v, want one change to the current snapshot", changes)
+	}
+
+	cur := Current()
+	if cur.RateLimit.Global.RPS != 20 || cur.Log.Level != "warn" {
+		t.Fatalf("snapshot = rps %v, level %q, want the new values", cur.RateLimit.Global.RPS, cur.Log.Level)
+	}
+	if cur.Ports["client"].Port != "8081" {
+		t.Fatalf("port = %s, want the running 8081 until a restart", cur.Ports["client"].Port)
+	}
+	if C.RateLimit.Global.RPS != 10 {
+		t.Fatal("reload changed the boot config C")
+	}
+
+	// nothing new: the restart key is still reported, subscribers are not called
+	ch, err = Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.Keys) != 0 || !slices.Equal(ch.Restart, []string{"ports.client.port"}) || len(changes) != 1 {
+		t.Fatalf("second reload = %+v with %d notifications", ch, len(changes))
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	write := initReload(t, reloadBase)
+	called := false
+	Subscribe(func(Change) { called = true })
+	before := Current()
+
+	write(`
+ports:
+  client: {port: "8081"}
+log:
+  level: loud
+rateLimit:
+  global: {rps: 0, burst: 20}
+`)
+	_, err := Reload()
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Problems) != 2 {
+		t.Fatalf("err = %v, want both problems", err)
+	}
+	if Current() != before || called {
+		t.Fatal("an invalid config was applied")
+	}
+
+	// the next valid file is compared against the running config
+	write(`
+ports:
+  client: {port: "8081"}
+log:
+  level: debug
+rateLimit:
+  global: {rps: 10, burst: 20}
+`)
+	ch, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ch.Keys, []string{"log.level"}) || !called {
+		t.Fatalf("keys = %q, want log.level", ch.Keys)
+	}
+}
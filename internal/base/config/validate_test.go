@@ -0,0 +1,202 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// validateYAML resolves body as config.yaml and validates the result,
+// returning the keys of the problems found.
+func validateYAML(t *testing.T, body string) []string {
+	t.Helper()
+	eff := resolveLayers(t, layers{files: map[string]string{"config.yaml": body}})
+	cfg, err := eff.decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.Validate()
+	if err == nil {
+		return nil
+	}
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	keys := make([]string, 0, len(ve.Problems))
+	for _, p := range ve.Problems {
+		keys = append(keys, p.Key)
+	}
+	return keys
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string // problem keys, in order
+	}{
+		{
+			name: "two ports",
+			yaml: `
+ports:
+  client: {port: "8081"}
+  console: {port: "8082"}
+`,
+		},
+		{
+			name: "same port on every interface",
+			yaml: `
+ports:
+  client: {port: "8081"}
+  console: {port: "8081"}
+`,
+			want: []string{"ports.console.port"},
+		},
+		{
+			name: "same port, wildcard and one address",
+			yaml: `
+ports:
+  client: {ip: "127.0.0.1", port: "8081"}
+  console: {ip: "::", port: "8081"}
+`,
+			want: []string{"ports.console.port"},
+		},
+		{
+			name: "same port on different addresses",
+			yaml: `
+ports:
+  client: {ip: "127.0.0.1", port: "8081"}
+  console: {ip: "127.0.0.2", port: "8081"}
+`,
+		},
+		{
+			name: "same port, one disabled",
+			yaml: `
+ports:
+  client: {port: "8081"}
+  console: {port: "8081", disabled: true}
+`,
+		},
+		{
+			name: "three ports on one",
+			yaml: `
+ports:
+  a: {port: "8081"}
+  b: {port: "8081"}
+  c: {port: "8081"}
+`,
+			want: []string{"ports.b.port", "ports.c.port", "ports.c.port"},
+		},
+		{
+			name: "certFile without keyFile",
+			yaml: `
+env: prod
+db: {dsn: "postgres://app@db/app"}
+ports:
+  client: {port: "8443", tls: {enabled: true, certFile: /etc/tls/cert.pem}}
+`,
+			want: []string{"ports.client.tls.keyFile"},
+		},
+		{
+			name: "keyFile without certFile",
+			yaml: `
+env: prod
+db: {dsn: "postgres://app@db/app"}
+ports:
+  client: {port: "8443", tls: {enabled: true, keyFile: /etc/tls/key.pem}}
+`,
+			want: []string{"ports.client.tls.keyFile"},
+		},
+		{
+			name: "no certificate outside dev",
+			yaml: `
+env: prod
+db: {dsn: "postgres://app@db/app"}
+ports:
+  client: {port: "8443", tls: {enabled: true}}
+`,
+			want: []string{"ports.client.tls.certFile"},
+		},
+		{
+			name: "no certificate in dev",
+			yaml: `
+env: dev
+ports:
+  client: {port: "8443", tls: {enabled: true}}
+`,
+		},
+		{
+			name: "TLS disabled ignores the files",
+			yaml: `
+env: prod
+db: {dsn: "postgres://app@db/app"}
+ports:
+  client: {port: "8443", tls: {certFile: /etc/tls/cert.pem}}
+`,
+		},
+		{
+			name: "clientAuth without a CA",
+			yaml: `
+env: dev
+ports:
+  console: {port: "8443", tls: {enabled: true, clientAuth: optional}}
+`,
+			want: []string{"ports.console.tls.clientAuth"},
+		},
+		{
+			name: "prod without a DSN",
+			yaml: `
+env: prod
+ports:
+  client: {port: "8081"}
+`,
+			want: []string{"db.dsn"},
+		},
+		{
+			name: "origins with userinfo or a query",
+			yaml: `
+ports:
+  client: {port: "8081"}
+cors:
+  allowOrigins: ["https://app.example.com", "https://u@example.com", "https://example.com?a=1", "https://*.example.com"]
+`,
+			want: []string{"cors.allowOrigins[1]", "cors.allowOrigins[2]"},
+		},
+		{
+			name: "credentials with any origin",
+			yaml: `
+ports:
+  client: {port: "8081"}
+cors:
+  allowCredentials: true
+`,
+			want: []string{"cors.allowCredentials"},
+		},
+		{
+			name: "every problem at once",
+			yaml: `
+env: prod
+ports:
+  client: {port: "70000", tls: {enabled: true, certFile: /etc/tls/cert.pem, minVersion: "1.1"}}
+  console: {port: "8082"}
+  admin: {port: "8082"}
+`,
+			want: []string{
+				"ports.client.port",
+				"ports.client.tls.minVersion",
+				"db.dsn",
+				"ports.console.port",
+				"ports.client.tls.keyFile",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateYAML(t, tt.yaml)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("problems = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
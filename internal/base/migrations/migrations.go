@@ -0,0 +1,104 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql
+var sqlFS embed.FS
+
+// sqlFileName matches <version>_<name>.(up|down).sql, e.g. 20251017093000_add_orders.up.sql.
+var sqlFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Step mutates the schema inside the migration's transaction.
+type Step func(ctx context.Context, tx *gorm.DB) error
+
+// Migration is one versioned schema change. Versions are ordered numerically;
+// new ones use a UTC timestamp (YYYYMMDDHHMMSS) so branches rarely collide.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      Step
+	Down    Step // nil when the migration cannot be reverted
+}
+
+// goMigrations are migrations written in Go, for data changes SQL can't
+// express. Keep them in version order. Schema changes go in sql/ so they stay
+// as written when the entities change later.
+var goMigrations = []Migration{}
+
+// All returns Go and embedded SQL migrations sorted by version.
+func All() ([]Migration, error) {
+	sqlMigrations, err := loadSQL(sqlFS)
+	if err != nil {
+		return nil, err
+	}
+	all := append(append([]Migration(nil), goMigrations...), sqlMigrations...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	for i := 1; i < len(all); i++ {
+		if all[i].Version == all[i-1].Version {
+			return nil, fmt.Errorf("migrations: duplicate version %d (%s, %s)", all[i].Version, all[i-1].Name, all[i].Name)
+		}
+	}
+	return all, nil
+}
+
+func loadSQL(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := sqlFileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrations: bad version in %s: %w", e.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, path.Join("sql", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrations: version %d used by %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = execSQL(string(body))
+		} else {
+			mig.Down = execSQL(string(body))
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == nil {
+			return nil, fmt.Errorf("migrations: %d_%s has no .up.sql", mig.Version, mig.Name)
+		}
+		out = append(out, *mig)
+	}
+	return out, nil
+}
+
+func execSQL(query string) Step {
+	return func(ctx context.Context, tx *gorm.DB) error {
+		return tx.WithContext(ctx).Exec(query).Error
+	}
+}
@@ -0,0 +1,203 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1) // every connection would get its own memory database
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return db
+}
+
+func TestLoadSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []string // <version>_<name>, with "-" suffix when there is no down
+		wantErr string
+	}{
+		{
+			name: "pairs and up only",
+			files: fstest.MapFS{
+				"sql/2_orders.up.sql":   {Data: []byte("CREATE TABLE orders (id int)")},
+				"sql/2_orders.down.sql": {Data: []byte("DROP TABLE orders")},
+				"sql/1_seed.up.sql":     {Data: []byte("SELECT 1")},
+				"sql/README.md":         {Data: []byte("ignored")},
+			},
+			want: []string{"1_seed-", "2_orders"},
+		},
+		{
+			name: "down without up",
+			files: fstest.MapFS{
+				"sql/1_orders.down.sql": {Data: []byte("DROP TABLE orders")},
+			},
+			wantErr: "has no .up.sql",
+		},
+		{
+			name: "two names for one version",
+			files: fstest.MapFS{
+				"sql/1_orders.up.sql": {Data: []byte("SELECT 1")},
+				"sql/1_users.up.sql":  {Data: []byte("SELECT 1")},
+			},
+			wantErr: "version 1 used by",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migs, err := loadSQL(tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range migs {
+				s := fmt.Sprintf("%d_%s", m.Version, m.Name)
+				if m.Down == nil {
+					s += "-"
+				}
+				got = append(got, s)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("migrations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAll(t *testing.T) {
+	all, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.IsSortedFunc(all, func(a, b Migration) int { return int(a.Version - b.Version) }) {
+		t.Fatal("migrations are not sorted by version")
+	}
+	for _, m := range all {
+		if m.Up == nil {
+			t.Errorf("%d_%s has no up step", m.Version, m.Name)
+		}
+	}
+}
+
+// step records its name in log when run.
+func step(log *[]string, name string) Step {
+	return func(ctx context.Context, tx *gorm.DB) error {
+		*log = append(*log, name)
+		return nil
+	}
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	ctx := context.Background()
+	var log []string
+	m := &Migrator{db: newTestDB(t), migrations: []Migration{
+		{Version: 1, Name: "one", Up: step(&log, "up 1"), Down: step(&log, "down 1")},
+		{Version: 2, Name: "two", Up: step(&log, "up 2"), Down: step(&log, "down 2")},
+		{Version: 3, Name: "three", Up: step(&log, "up 3")},
+	}}
+
+	applied, err := m.Up(ctx)
+	if err != nil || len(applied) != 3 {
+		t.Fatalf("up: applied %d, err %v", len(applied), err)
+	}
+	// a second run has nothing left to do
+	if applied, err := m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("second up: applied %d, err %v", len(applied), err)
+	}
+
+	// 3 has no down step, so nothing is reverted
+	if _, err := m.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "irreversible") {
+		t.Fatalf("down over an irreversible migration: err = %v", err)
+	}
+	m.migrations[2].Down = step(&log, "down 3")
+	if reverted, err := m.Down(ctx, 2); err != nil || len(reverted) != 2 {
+		t.Fatalf("down 2: reverted %d, err %v", len(reverted), err)
+	}
+	want := []string{"up 1", "up 2", "up 3", "down 3", "down 2"}
+	if !slices.Equal(log, want) {
+		t.Fatalf("steps = %v, want %v", log, want)
+	}
+
+	st, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st) != 3 || st[0].AppliedAt == nil || st[1].AppliedAt != nil || st[2].AppliedAt != nil {
+		t.Fatalf("status after down = %+v", st)
+	}
+}
+
+func TestMigratorFailedStepRollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	m := &Migrator{db: db, migrations: []Migration{
+		{Version: 1, Name: "bad", Up: func(ctx context.Context, tx *gorm.DB) error {
+			if err := tx.Exec("CREATE TABLE t (id int)").Error; err != nil {
+				return err
+			}
+			return tx.Exec("NOT SQL").Error
+		}},
+	}}
+	if _, err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "up 1_bad") {
+		t.Fatalf("err = %v, want the failing migration named", err)
+	}
+	if db.Migrator().HasTable("t") {
+		t.Fatal("table of the failed migration was kept")
+	}
+	var n int64
+	db.Model(&schemaMigration{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("%d schema_migrations rows after a failed migration", n)
+	}
+}
+
+func TestMigratorStatusUnknownVersion(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	var log []string
+	old := &Migrator{db: db, migrations: []Migration{
+		{Version: 1, Name: "one", Up: step(&log, "up 1")},
+		{Version: 2, Name: "two", Up: step(&log, "up 2")},
+	}}
+	if _, err := old.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a binary that doesn't know version 2, e.g. after a rollback
+	m := &Migrator{db: db, migrations: old.migrations[:1]}
+	st, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st) != 2 || st[1].Version != 2 || !st[1].Missing {
+		t.Fatalf("status = %+v, want version 2 missing", st)
+	}
+	if _, err := m.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "unknown to this binary") {
+		t.Fatalf("down of an unknown version: err = %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockKey is the Postgres advisory lock id shared by every replica.
+const lockKey int64 = 0x67745f6d6967 // "gt_mig"
+
+// schemaMigration is a row of the schema_migrations table.
+type schemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255;not null"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+// Status describes one migration known to the binary or the database.
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	Missing   bool // applied in the database but unknown to this binary
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New returns a Migrator over every registered migration.
+func New(db *gorm.DB) (*Migrator, error) {
+	if db == nil {
+		return nil, errors.New("migrations: db is nil")
+	}
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: all}, nil
+}
+
+// Up applies all pending migrations in version order and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := apply(ctx, conn, mig); err != nil {
+				return err
+			}
+			log.Printf("migrate: applied %d_%s", mig.Version, mig.Name)
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, nil
+	}
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+		var rows []schemaMigration
+		if err := conn.WithContext(ctx).Order("version DESC").Limit(steps).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			mig, ok := known[row.Version]
+			if !ok {
+				return fmt.Errorf("migrations: %d_%s is applied but unknown to this binary", row.Version, row.Name)
+			}
+			if mig.Down == nil {
+				return fmt.Errorf("migrations: %d_%s is irreversible", mig.Version, mig.Name)
+			}
+			if err := revert(ctx, conn, mig); err != nil {
+				return err
+			}
+			log.Printf("migrate: reverted %d_%s", mig.Version, mig.Name)
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every migration with its applied time, plus applied versions
+// this binary does not know about.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.db.WithContext(ctx).AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, err
+	}
+	done, err := appliedVersions(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{Version: mig.Version, Name: mig.Name}
+		if row, ok := done[mig.Version]; ok {
+			at := row.AppliedAt
+			st.AppliedAt = &at
+			delete(done, mig.Version)
+		}
+		out = append(out, st)
+	}
+	for _, row := range done {
+		at := row.AppliedAt
+		out = append(out, Status{Version: row.Version, Name: row.Name, AppliedAt: &at, Missing: true})
+	}
+	return out, nil
+}
+
+// locked runs fn on a single pinned connection holding the advisory lock, so
+// concurrent replicas booting with autoMigrate wait for each other.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if isPostgres(conn) {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+				return fmt.Errorf("migrations: acquire lock: %w", err)
+			}
+			defer func() {
+				if err := conn.Exec("SELECT pg_advisory_unlock(?)", lockKey).Error; err != nil {
+					log.Printf("migrate: release lock: %v", err)
+				}
+			}()
+		}
+		if err := conn.AutoMigrate(&schemaMigration{}); err != nil {
+			return fmt.Errorf("migrations: ensure schema_migrations: %w", err)
+		}
+		return fn(conn)
+	})
+}
+
+func apply(ctx context.Context, conn *gorm.DB, mig Migration) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if err := mig.Up(ctx, tx); err != nil {
+			return fmt.Errorf("migrations: up %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		return tx.Create(&schemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}).Error
+	})
+}
+
+func revert(ctx context.Context, conn *gorm.DB, mig Migration) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if err := mig.Down(ctx, tx); err != nil {
+			return fmt.Errorf("migrations: down %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		return tx.Delete(&schemaMigration{}, "version = ?", mig.Version).Error
+	})
+}
+
+func appliedVersions(ctx context.Context, db *gorm.DB) (map[int64]schemaMigration, error) {
+	var rows []schemaMigration
+	if err := db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[int64]schemaMigration, len(rows))
+	for _, r := range rows {
+		out[r.Version] = r
+	}
+	return out, nil
+}
+
+func isPostgres(db *gorm.DB) bool {
+	return db.Dialector != nil && db.Dialector.Name() == "postgres"
+}
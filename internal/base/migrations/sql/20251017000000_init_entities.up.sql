@@ -0,0 +1,10 @@
+CREATE TABLE user_entities (
+    id            bigserial    PRIMARY KEY,
+    login_id      varchar(128) NOT NULL,
+    nickname      varchar(128),
+    password_hash varchar(256) NOT NULL,
+    created_at    timestamptz,
+    updated_at    timestamptz
+);
+
+CREATE UNIQUE INDEX idx_user_entities_login_id ON user_entities (login_id);
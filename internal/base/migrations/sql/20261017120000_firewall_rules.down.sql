@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS firewall_audit_entities;
+DROP TABLE IF EXISTS firewall_rule_entities;
@@ -0,0 +1,30 @@
+CREATE TABLE firewall_rule_entities (
+    id         bigserial    PRIMARY KEY,
+    action     varchar(8)   NOT NULL,
+    cidr       varchar(64)  NOT NULL,
+    port       varchar(64)  NOT NULL DEFAULT '',
+    reason     varchar(256),
+    expires_at timestamptz,
+    created_by varchar(128),
+    created_at timestamptz
+);
+
+CREATE INDEX idx_firewall_rule_entities_cidr ON firewall_rule_entities (cidr);
+CREATE INDEX idx_firewall_rule_entities_expires_at ON firewall_rule_entities (expires_at);
+
+CREATE TABLE firewall_audit_entities (
+    id         bigserial    PRIMARY KEY,
+    rule_id    bigint       NOT NULL,
+    op         varchar(16)  NOT NULL,
+    action     varchar(8)   NOT NULL,
+    cidr       varchar(64)  NOT NULL,
+    port       varchar(64)  NOT NULL DEFAULT '',
+    reason     varchar(256),
+    expires_at timestamptz,
+    actor      varchar(128),
+    actor_ip   varchar(64),
+    created_at timestamptz
+);
+
+CREATE INDEX idx_firewall_audit_entities_rule_id ON firewall_audit_entities (rule_id);
+CREATE INDEX idx_firewall_audit_entities_created_at ON firewall_audit_entities (created_at);
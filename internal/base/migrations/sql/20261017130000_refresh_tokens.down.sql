@@ -0,0 +1 @@
+DROP TABLE IF EXISTS refresh_token_entities;
@@ -0,0 +1,13 @@
+CREATE TABLE refresh_token_entities (
+    token_hash   varchar(64)  PRIMARY KEY,
+    login_id     varchar(128) NOT NULL,
+    device       varchar(64)  NOT NULL,
+    access_token text         NOT NULL,
+    rotated_at   timestamptz,
+    expires_at   timestamptz  NOT NULL,
+    created_at   timestamptz
+);
+
+CREATE INDEX idx_refresh_token_family ON refresh_token_entities (login_id, device);
+CREATE INDEX idx_refresh_token_entities_access_token ON refresh_token_entities (access_token);
+CREATE INDEX idx_refresh_token_entities_expires_at ON refresh_token_entities (expires_at);
@@ -2,19 +2,31 @@ package repos
 
 import (
 	"log"
+	"time"
 
+	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/tracing"
 	"github.com/wiidz/gin_template/internal/domain/shared/user/entity"
 
 	"github.com/wiidz/goutil/mngs/psqlMng"
 	repoMng "github.com/wiidz/goutil/mngs/repoMng"
+	"gorm.io/gorm"
 )
 
 // M is the global repository manager (supports multi-DB via repoMng if needed).
 var M *repoMng.Manager
 
 // User is the concrete implementation of the user repository interface.
+// DB backs usersvc.TokenStore, whose rotation needs conditional updates.
 var User = struct {
 	Repo *repoMng.Repo[entity.UserEntity]
+	DB   *gorm.DB
+}{}
+
+// Firewall holds the database of firewallsvc, whose writes span the rules
+// and audit tables in one transaction.
+var Firewall = struct {
+	DB *gorm.DB
 }{}
 
 // Setup initializes the global manager and entity repositories.
@@ -27,8 +39,16 @@ func Setup(psql *psqlMng.Manager) {
 		return
 	}
 
+	// child spans for every query issued with a request context
+	if err := psql.DB().Use(tracing.GormPlugin{}); err != nil {
+		log.Printf("repos: gorm tracing plugin: %v", err)
+	}
+	// query logs carry the request's rid / login_id / trace_id
+	psql.DB().Logger = logger.Gorm{SlowThreshold: 200 * time.Millisecond}
 	M.SetupDefault(psql.DB())
 
 	// initialize entity repos on default DB
 	User.Repo = repoMng.RepoOf[entity.UserEntity](M.Default().DB())
+	User.DB = M.Default().DB()
+	Firewall.DB = M.Default().DB()
 }
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"log"
+	"strings"
+
+	"github.com/wiidz/gin_template/internal/base/config"
+	"github.com/wiidz/gin_template/internal/common/firewall"
+	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/middleware"
+)
+
+// applyRuntimeConfig pushes the hot-reloadable config sections into the
+// logger and middleware at boot, then again after every accepted reload.
+func applyRuntimeConfig() {
+	cfg := config.Current()
+	applyLogLevels(cfg.Log)
+	applyCORS(cfg.CORS)
+	firewall.SetRules(firewall.SourceConfig, firewallRules(cfg))
+	firewall.SetBanPolicy(banPolicy(cfg.Autoban))
+	middleware.SetRateLimitPolicies(ratePolicies(cfg.RateLimit.Policies))
+	middleware.SetMetricsAccess(metricsAccess(cfg.Metrics))
+
+	config.Subscribe(func(ch config.Change) {
+		if ch.Changed("log.") {
+			applyLogLevels(ch.New.Log)
+		}
+		if ch.Changed("rateLimit.global.") {
+			middleware.SetRateLimit(ch.New.RateLimit.Global.RPS, ch.New.RateLimit.Global.Burst)
+		}
+		if ch.Changed("rateLimit.ip.") {
+			middleware.SetRateLimitIP(ch.New.RateLimit.IP.RPS, ch.New.RateLimit.IP.Burst)
+		}
+		if ch.Changed("autoban.") {
+			firewall.SetBanPolicy(banPolicy(ch.New.Autoban))
+		}
+		if ch.Changed("rateLimit.policies") {
+			middleware.SetRateLimitPolicies(ratePolicies(ch.New.RateLimit.Policies))
+		}
+		if ch.Changed("cors.") {
+			applyCORS(ch.New.CORS)
+		}
+		if ch.Changed("denylist.") || ch.Changed("firewall.allow") {
+			firewall.SetRules(firewall.SourceConfig, firewallRules(ch.New))
+		}
+		if ch.Changed("metrics.") {
+			middleware.SetMetricsAccess(metricsAccess(ch.New.Metrics))
+		}
+	})
+}
+
+// applyCORS swaps the CORS policies; an invalid set keeps the current one.
+func applyCORS(cfg config.CORSConfig) {
+	ports := make(map[string]middleware.CORSPolicy, len(cfg.Ports))
+	for name, p := range cfg.Ports {
+		ports[name] = corsPolicy(p)
+	}
+	if err := middleware.SetCORSPolicies(corsPolicy(cfg.Default()), ports); err != nil {
+		log.Printf("config: cors: %v", err)
+	}
+}
+
+func corsPolicy(p config.CORSPolicy) middleware.CORSPolicy {
+	return middleware.CORSPolicy{
+		AllowOrigins:     p.AllowOrigins,
+		AllowMethods:     p.AllowMethods,
+		AllowHeaders:     p.AllowHeaders,
+		ExposeHeaders:    p.ExposeHeaders,
+		AllowCredentials: p.AllowCredentials,
+		MaxAge:           p.MaxAge,
+	}
+}
+
+// applyLogLevels resets the root and per-logger levels to the config,
+// dropping changes made through the console's /admin/log-level.
+func applyLogLevels(cfg config.LogConfig) {
+	if err := logger.SetLevel(cfg.Level); err != nil {
+		log.Printf("config: %v", err)
+	}
+	if err := logger.SetLevels(cfg.Levels); err != nil {
+		log.Printf("config: %v", err)
+	}
+}
+
+func ratePolicies(cfg []config.RateLimitPolicy) []middleware.RateLimitPolicy {
+	out := make([]middleware.RateLimitPolicy, 0, len(cfg))
+	for _, p := range cfg {
+		out = append(out, middleware.RateLimitPolicy{
+			Name:    p.Name,
+			Ports:   p.Ports,
+			Path:    p.Path,
+			Methods: p.Methods,
+			Roles:   p.Roles,
+			Key:     p.Key,
+			Header:  p.Header,
+			RPS:     p.RPS,
+			Burst:   p.Burst,
+
+			APIKeyHashes: lower(p.APIKeyHashes),
+			Values:       p.Values,
+		})
+	}
+	return out
+}
+
+func lower(ss []string) []string {
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = strings.ToLower(s)
+	}
+	return out
+}
+
+// firewallRules turns denylist.ips and firewall.allow into rules; values
+// were checked by config validation.
+func firewallRules(cfg *config.AppConfig) []firewall.Rule {
+	var out []firewall.Rule
+	add := func(action, port, s string) {
+		p, err := firewall.ParsePrefix(s)
+		if err != nil {
+			log.Printf("config: firewall: %v", err)
+			return
+		}
+		out = append(out, firewall.Rule{Action: action, Prefix: p, Port: port})
+	}
+	for _, ip := range cfg.Denylist.IPs {
+		add(firewall.Deny, "", ip)
+	}
+	for port, ips := range cfg.Firewall.Allow {
+		for _, ip := range ips {
+			add(firewall.Allow, port, ip)
+		}
+	}
+	return out
+}
+
+func metricsAccess(cfg config.MetricsConfig) middleware.MetricsAccessPolicy {
+	p := middleware.MetricsAccessPolicy{BearerToken: cfg.BearerToken}
+	for _, s := range cfg.Allow {
+		prefix, err := firewall.ParsePrefix(s)
+		if err != nil {
+			log.Printf("config: metrics: %v", err)
+			continue
+		}
+		p.Allow = append(p.Allow, prefix)
+	}
+	return p
+}
+
+// banPolicy converts the autoban section; nil when it is disabled.
+func banPolicy(cfg config.AutobanConfig) *firewall.BanPolicy {
+	if !cfg.Enabled {
+		return nil
+	}
+	p := &firewall.BanPolicy{BanTime: cfg.BanTime, MaxBanTime: cfg.MaxBanTime, ForgetAfter: cfg.ForgetAfter}
+	for _, ip := range cfg.Ignore {
+		pr, err := firewall.ParsePrefix(ip)
+		if err != nil {
+			log.Printf("config: autoban: %v", err)
+			continue
+		}
+		p.Ignore = append(p.Ignore, pr)
+	}
+	for _, r := range cfg.Rules {
+		p.Rules = append(p.Rules, firewall.BanRule{
+			Name:     r.Name,
+			Ports:    r.Ports,
+			Path:     r.Path,
+			Statuses: r.Status,
+			MaxRetry: r.MaxRetry,
+			FindTime: r.FindTime,
+		})
+	}
+	return p
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wiidz/gin_template/internal/base/config"
+	"github.com/wiidz/gin_template/internal/common/firewall"
+	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/middleware"
+)
+
+// initConfig loads config.yaml with body from a temporary directory and
+// returns a function rewriting it.
+func initConfig(t *testing.T, body string) func(string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(body string) {
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(body)
+	config.Init(config.Options{Dir: filepath.Dir(path)})
+	return write
+}
+
+func TestReloadMetricsAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	write := initConfig(t, "metrics:\n  bearerToken: old\n")
+	applyRuntimeConfig()
+	t.Cleanup(func() { middleware.SetMetricsAccess(middleware.MetricsAccessPolicy{}) })
+
+	e := gin.New()
+	e.GET("/metrics", middleware.MetricsAccess(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	scrape := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = "192.0.2.1:5000"
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w.Code
+	}
+	if got := scrape("old"); got != http.StatusOK {
+		t.Fatalf("scrape with the boot token = %d, want 200", got)
+	}
+
+	write("metrics:\n  bearerToken: new\n")
+	ch, err := config.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ch.Changed("metrics.") {
+		t.Fatalf("changed keys = %v, want metrics.bearerToken", ch.Keys)
+	}
+	if got := config.Current().Metrics.BearerToken; got != "new" {
+		t.Fatalf("snapshot token = %q, want new", got)
+	}
+	if got := scrape("new"); got != http.StatusOK {
+		t.Fatalf("scrape with the new token = %d, want 200", got)
+	}
+	if got := scrape("old"); got != http.StatusForbidden {
+		t.Fatalf("scrape with the old token = %d, want 403", got)
+	}
+}
+
+func TestReloadAppliesRuntimeConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	write := initConfig(t, `
+log:
+  level: info
+cors:
+  allowOrigins: ["https://a.example.com"]
+`)
+	applyRuntimeConfig()
+	level := logger.Level()
+	t.Cleanup(func() {
+		_ = logger.SetLevel(level.String())
+		_ = middleware.SetCORSPolicies(middleware.CORSPolicy{}, nil)
+		firewall.SetRules(firewall.SourceConfig, nil)
+	})
+
+	e := gin.New()
+	e.Use(middleware.CORS())
+	e.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	allowed := func(origin string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w.Header().Get("Access-Control-Allow-Origin") == origin
+	}
+	if !allowed("https://a.example.com") || allowed("https://b.example.com") {
+		t.Fatal("boot CORS origins not applied")
+	}
+
+	write(`
+log:
+  level: warn
+cors:
+  allowOrigins: ["https://b.example.com"]
+denylist:
+  ips: ["203.0.113.0/24"]
+`)
+	if _, err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := logger.Level(); got != zapcore.WarnLevel {
+		t.Errorf("log level = %s, want warn", got)
+	}
+	if allowed("https://a.example.com") || !allowed("https://b.example.com") {
+		t.Error("reloaded CORS origins not applied")
+	}
+	if firewall.Check("client", "203.0.113.9").Allowed {
+		t.Error("reloaded denylist not applied")
+	}
+}
@@ -2,49 +2,221 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/wiidz/gin_template/internal/base/config"
+	"github.com/wiidz/gin_template/internal/common/firewall"
 	"github.com/wiidz/gin_template/internal/common/middleware"
-	clientport "github.com/wiidz/gin_template/internal/domain/client"
-	consoleport "github.com/wiidz/gin_template/internal/domain/console"
+	"github.com/wiidz/gin_template/internal/common/response"
 )
 
+const (
+	defaultReadTimeout       = 10 * time.Second
+	defaultWriteTimeout      = 10 * time.Second
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+	defaultMaxBodyBytes      = 4 << 20
+)
+
+// Port declares one HTTP surface (client, console, partner API, ...). Its
+// address comes from ports.<Name> in config, which may also override the
+// timeouts declared here.
+type Port struct {
+	Name string
+	// Routes registers the port's handlers on an engine that already carries
+	// the default middleware stack and Middleware.
+	Routes func(e *gin.Engine)
+	// Middleware returns handlers run after the default stack, e.g. extra
+	// auth for an internal admin port. Optional.
+	Middleware   func() []gin.HandlerFunc
+	ReadTimeout  time.Duration // 0 means 10s
+	WriteTimeout time.Duration // 0 means 10s
+	MaxBodyBytes int64         // 0 means 4 MB
+}
+
+type listener struct {
+	name   string
+	addr   string
+	server *http.Server
+	certs  *certStore // nil for plain HTTP
+}
+
 type Server struct {
-	clientEngine  *gin.Engine
-	consoleEngine *gin.Engine
-	clientServer  *http.Server
-	consoleServer *http.Server
+	listeners []*listener
 }
 
-func NewServer() *Server {
-	// 1) 初始化 gin
+// NewServer builds an engine for every enabled port. Each registered port
+// needs a ports.<name> entry in config, and every entry a registered port.
+func NewServer(ports ...Port) (*Server, error) {
 	log.Printf("boot: init gin")
+	cfg := config.C.Ports
+
+	registered := make(map[string]bool, len(ports))
+	for _, p := range ports {
+		registered[p.Name] = true
+	}
+	var unknown []string
+	for name := range cfg {
+		if !registered[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("server: config declares unregistered port(s) %s", strings.Join(unknown, ", "))
+	}
+
+	s := &Server{}
+	for _, p := range ports {
+		pc, ok := cfg[p.Name]
+		if !ok {
+			return nil, fmt.Errorf("server: port %q has no ports.%s entry in config", p.Name, p.Name)
+		}
+		if pc.Disabled {
+			log.Printf("boot: port %s disabled", p.Name)
+			continue
+		}
+		log.Printf("boot: build %s engine", p.Name)
+		engine := buildWithRoutePrefix(p.Name, func() *gin.Engine { return buildEngine(p) })
+		// gin trusts every proxy by default, letting any client spoof ClientIP
+		if err := engine.SetTrustedProxies(pc.TrustedProxies); err != nil {
+			s.closeCerts()
+			return nil, fmt.Errorf("server: ports.%s.trustedProxies: %w", p.Name, err)
+		}
+		l := &listener{
+			name: p.Name,
+			addr: pc.Addr(),
+			server: &http.Server{
+				Addr:              pc.Addr(),
+				Handler:           engine,
+				ReadTimeout:       firstNonZero(pc.ReadTimeout, p.ReadTimeout, defaultReadTimeout),
+				WriteTimeout:      firstNonZero(pc.WriteTimeout, p.WriteTimeout, defaultWriteTimeout),
+				ReadHeaderTimeout: firstNonZero(pc.ReadHeaderTimeout, defaultReadHeaderTimeout),
+				IdleTimeout:       firstNonZero(pc.IdleTimeout, defaultIdleTimeout),
+				MaxHeaderBytes:    pc.MaxHeaderBytes,
+			},
+		}
+		if pc.TLS.Enabled {
+			tlsCfg, certs, err := newTLSConfig(p.Name, pc.TLS, config.C.Env)
+			if err != nil {
+				s.closeCerts()
+				return nil, err
+			}
+			l.server.TLSConfig = tlsCfg
+			l.certs = certs
+			if err := certs.watch(); err != nil {
+				s.closeCerts()
+				return nil, err
+			}
+		}
+		s.listeners = append(s.listeners, l)
+	}
+	if len(s.listeners) == 0 {
+		return nil, errors.New("server: no enabled ports")
+	}
+
+	applyRuntimeConfig()
+	return s, nil
+}
+
+func buildEngine(p Port) *gin.Engine {
+	e := gin.New()
+	e.Use(DefaultMiddleware(p.Name)...)
+	limits := requestLimits(config.C.Ports[p.Name], p)
+	// Timeout first: its deadlines also cover reading chunked bodies
+	e.Use(middleware.Timeout(limits), middleware.BodyLimit(limits))
+	if p.Middleware != nil {
+		e.Use(p.Middleware()...)
+	}
+	p.Routes(e)
+	return e
+}
+
+// DefaultMiddleware is the stack every port gets before its own middleware.
+func DefaultMiddleware(port string) []gin.HandlerFunc {
+	limits := config.Current().RateLimit
+	security := securityPolicy(config.C.Ports[port])
+	return []gin.HandlerFunc{
+		func(c *gin.Context) { c.Set("port", port); c.Next() },
+		response.ErrorFormat(config.C.Ports[port].ErrorFormat),
+		response.Formats(config.C.Ports[port].Formats),
+		middleware.Metrics(),
+		middleware.Tracing(),
+		// Structured logs (zap)
+		middleware.RequestID(),
+		middleware.RequestLogger(),
+		middleware.AccessLog(),
+		middleware.HTTPSRedirect(security),
+		middleware.SecurityHeaders(security),
+		middleware.Recovery(),
+		middleware.CORS(),
+		middleware.Firewall(),
+		middleware.Autoban(),
+		middleware.RateLimit(limits.Global.RPS, limits.Global.Burst),
+		// a matching policy replaces the IP limit
+		middleware.RateLimitPolicies(),
+		middleware.RateLimitIP(limits.IP.RPS, limits.IP.Burst),
+	}
+}
+
+func requestLimits(pc config.PortConfig, p Port) middleware.RequestLimits {
+	lc := pc.Limits
+	l := middleware.RequestLimits{
+		MaxBodyBytes:  lc.MaxBodyBytes,
+		Timeout:       lc.Timeout,
+		TimeoutStatus: lc.TimeoutStatus,
+		LiftedTimeout: lc.LiftedTimeout,
+		ReadTimeout:   firstNonZero(pc.ReadTimeout, p.ReadTimeout, defaultReadTimeout),
+		WriteTimeout:  firstNonZero(pc.WriteTimeout, p.WriteTimeout, defaultWriteTimeout),
+	}
+	if l.MaxBodyBytes == 0 {
+		l.MaxBodyBytes = p.MaxBodyBytes
+	}
+	if l.MaxBodyBytes == 0 {
+		l.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	for _, r := range lc.Routes {
+		l.Routes = append(l.Routes, middleware.RouteLimit{
+			Path:         r.Path,
+			Methods:      r.Methods,
+			MaxBodyBytes: r.MaxBodyBytes,
+			Timeout:      r.Timeout,
+		})
+	}
+	return l
+}
 
-	// 2) 构建路由（client）
-	log.Printf("boot: build client engine")
-	clientEngine := buildWithRoutePrefix("client", clientport.BuildEngine)
-	clientEngine.Use(func(c *gin.Context) { c.Set("port", "client"); c.Next() })
-	clientEngine.Use(middleware.IPDenylist())
-	clientEngine.Use(middleware.RateLimit(100, 200))
-	clientEngine.Use(middleware.RateLimitIP(50, 100))
-	sdecorate(clientEngine)
-
-	// 3) 构建路由（console）
-	log.Printf("boot: build console engine")
-	consoleEngine := buildWithRoutePrefix("console", consoleport.BuildEngine)
-	consoleEngine.Use(func(c *gin.Context) { c.Set("port", "console"); c.Next() })
-	consoleEngine.Use(middleware.IPDenylist())
-	consoleEngine.Use(middleware.RateLimit(100, 200))
-	consoleEngine.Use(middleware.RateLimitIP(50, 100))
-	sdecorate(consoleEngine)
-
-	return &Server{clientEngine: clientEngine, consoleEngine: consoleEngine}
+func securityPolicy(pc config.PortConfig) middleware.SecurityPolicy {
+	sc := pc.Security
+	p := middleware.SecurityPolicy{
+		HSTSMaxAge:            sc.HSTS.MaxAge,
+		HSTSIncludeSubdomains: sc.HSTS.IncludeSubdomains,
+		HSTSPreload:           sc.HSTS.Preload,
+		CSP:                   sc.CSP,
+		FrameOptions:          sc.FrameOptions,
+		ReferrerPolicy:        sc.ReferrerPolicy,
+		PermissionsPolicy:     sc.PermissionsPolicy,
+		COOP:                  sc.COOP,
+		COEP:                  sc.COEP,
+		ContentTypeOptions:    sc.ContentTypeOptions,
+		RedirectHTTPS:         sc.RedirectHTTPS,
+		HTTPSPort:             sc.HTTPSPort,
+	}
+	for _, s := range pc.TrustedProxies {
+		// validated by config's ipnet
+		if prefix, err := firewall.ParsePrefix(s); err == nil {
+			p.TrustedProxies = append(p.TrustedProxies, prefix)
+		}
+	}
+	return p
 }
 
 func buildWithRoutePrefix(prefix string, builder func() *gin.Engine) *gin.Engine {
@@ -60,43 +232,49 @@ func buildWithRoutePrefix(prefix string, builder func() *gin.Engine) *gin.Engine
 	return e
 }
 
-func sdecorate(e *gin.Engine) {
-	// Structured logs (zap)
-	e.Use(
-		middleware.RequestID(),
-		middleware.AccessLog(),
-		middleware.Recovery(),
-		middleware.CORS(),
-	)
+// Start serves every port and returns on the first listener error (or nil
+// once all are closed by Shutdown).
+func (s *Server) Start() error {
+	errCh := make(chan error, len(s.listeners))
+	for _, l := range s.listeners {
+		go func(l *listener) {
+			if l.server.TLSConfig != nil {
+				log.Printf("%s listening on %s (TLS)", l.name, l.addr)
+				// certificates come from TLSConfig, see newTLSConfig
+				errCh <- l.server.ListenAndServeTLS("", "")
+				return
+			}
+			log.Printf("%s listening on %s", l.name, l.addr)
+			errCh <- l.server.ListenAndServe()
+		}(l)
+	}
+	for range s.listeners {
+		if err := <-errCh; err != nil && err != http.ErrServerClosed {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *Server) Start(clientAddr, consoleAddr string) error {
-	s.clientServer = &http.Server{Addr: clientAddr, Handler: s.clientEngine, ReadTimeout: 10 * time.Second, WriteTimeout: 10 * time.Second}
-	s.consoleServer = &http.Server{Addr: consoleAddr, Handler: s.consoleEngine, ReadTimeout: 10 * time.Second, WriteTimeout: 10 * time.Second}
-
-	errCh := make(chan error, 2)
-	go func() {
-		log.Printf("client listening on %s", clientAddr)
-		errCh <- s.clientServer.ListenAndServe()
-	}()
-	go func() {
-		log.Printf("console listening on %s", consoleAddr)
-		errCh <- s.consoleServer.ListenAndServe()
-	}()
-
-	// return on first error (or nil only if both closed by Shutdown)
-	if err := <-errCh; err != nil && err != http.ErrServerClosed {
-		return err
+func (s *Server) Shutdown(ctx context.Context) error {
+	for _, l := range s.listeners {
+		_ = l.server.Shutdown(ctx)
 	}
+	s.closeCerts()
 	return nil
 }
 
-func (s *Server) Shutdown(ctx context.Context) error {
-	if s.clientServer != nil {
-		_ = s.clientServer.Shutdown(ctx)
+func (s *Server) closeCerts() {
+	for _, l := range s.listeners {
+		l.certs.close()
 	}
-	if s.consoleServer != nil {
-		_ = s.consoleServer.Shutdown(ctx)
+}
+
+func firstNonZero(ds ...time.Duration) time.Duration {
+	for _, d := range ds {
+		if d > 0 {
+			return d
+		}
 	}
-	return nil
+	return 0
 }
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/wiidz/gin_template/internal/base/config"
+)
+
+// certStore holds a port's certificate and client CA pool. Both are read on
+// every handshake, so replacing the files on disk takes effect for new
+// connections while open ones keep going.
+type certStore struct {
+	port     string
+	certFile string
+	keyFile  string
+	caFile   string
+
+	cert    atomic.Pointer[tls.Certificate]
+	cas     atomic.Pointer[x509.CertPool]
+	watcher *fsnotify.Watcher
+}
+
+// newTLSConfig builds the tls.Config of a port, generating a self-signed
+// certificate in dev when no files are configured.
+func newTLSConfig(port string, t config.TLSConfig, env string) (*tls.Config, *certStore, error) {
+	store := &certStore{port: port, certFile: t.CertFile, keyFile: t.KeyFile, caFile: t.ClientCAFile}
+	if t.CertFile == "" {
+		if env != "dev" && env != "development" {
+			return nil, nil, fmt.Errorf("tls: port %s has no certFile", port)
+		}
+		cert, err := selfSignedCert()
+		if err != nil {
+			return nil, nil, fmt.Errorf("tls: generate dev certificate: %w", err)
+		}
+		store.cert.Store(cert)
+		log.Printf("tls: %s uses a generated self-signed certificate (dev only)", port)
+	}
+	if err := store.load(); err != nil {
+		return nil, nil, err
+	}
+
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	if t.MinVersion == "1.3" {
+		base.MinVersion = tls.VersionTLS13
+	}
+	for _, name := range t.CipherSuites {
+		id, _ := config.CipherSuite(name) // checked by config validation
+		base.CipherSuites = append(base.CipherSuites, id)
+	}
+	if t.ClientCAFile != "" {
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+		if t.ClientAuth == "optional" {
+			base.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	cfg := base.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.Certificates = []tls.Certificate{*store.cert.Load()}
+		c.ClientCAs = store.cas.Load()
+		return c, nil
+	}
+	return cfg, store, nil
+}
+
+// load reads the configured files. On error the previous certificate and
+// CA pool stay in use.
+func (s *certStore) load() error {
+	var (
+		cert *tls.Certificate
+		pool *x509.CertPool
+	)
+	if s.certFile != "" {
+		c, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			return fmt.Errorf("tls: %s: load key pair: %w", s.port, err)
+		}
+		cert = &c
+	}
+	if s.caFile != "" {
+		pem, err := os.ReadFile(s.caFile)
+		if err != nil {
+			return fmt.Errorf("tls: %s: read client CA: %w", s.port, err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls: %s: no certificates in %s", s.port, s.caFile)
+		}
+	}
+	if cert != nil {
+		s.cert.Store(cert)
+	}
+	if pool != nil {
+		s.cas.Store(pool)
+	}
+	return nil
+}
+
+// watch reloads the store when one of its files changes. Directories are
+// watched rather than files so atomic renames and Kubernetes secret
+// symlink swaps are seen too.
+func (s *certStore) watch() error {
+	files := map[string]bool{}
+	for _, f := range []string{s.certFile, s.keyFile, s.caFile} {
+		if f != "" {
+			files[filepath.Clean(f)] = true
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	dirs := map[string]bool{}
+	for f := range files {
+		dir := filepath.Dir(f)
+		if !dirs[dir] {
+			if err := w.Add(dir); err != nil {
+				_ = w.Close()
+				return fmt.Errorf("tls: watch %s: %w", dir, err)
+			}
+			dirs[dir] = true
+		}
+	}
+	s.watcher = w
+
+	go func() {
+		var debounce *time.Timer
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Clean(ev.Name)
+				swapped := filepath.Base(name) == "..data" && dirs[filepath.Dir(name)]
+				if !files[name] && !swapped {
+					continue
+				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(200*time.Millisecond, func() {
+					if err := s.load(); err != nil {
+						log.Printf("%v; keeping previous certificate", err)
+						return
+					}
+					log.Printf("tls: %s certificates reloaded", s.port)
+				})
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("tls: %s watcher: %v", s.port, err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *certStore) close() {
+	if s != nil && s.watcher != nil {
+		_ = s.watcher.Close()
+	}
+}
+
+// selfSignedCert creates a short-lived certificate for localhost.
+func selfSignedCert() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"gin_template dev"}, CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		tmpl.DNSNames = append(tmpl.DNSNames, host)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
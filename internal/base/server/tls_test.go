@@ -0,0 +1,235 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wiidz/gin_template/internal/base/config"
+)
+
+// testCert is a certificate with its key, signed by parent (self-signed
+// when parent is nil).
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write stores the certificate (and key, when keyFile is set) as PEM.
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	writePEM(t, certFile, "CERTIFICATE", c.der)
+	if keyFile != "" {
+		der, err := x509.MarshalECPrivateKey(c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writePEM(t, keyFile, "EC PRIVATE KEY", der)
+	}
+}
+
+func (c *testCert) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveTLS serves 204s with cfg on a local port and returns its URL.
+func serveTLS(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler:  http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }),
+		ErrorLog: log.New(io.Discard, "", 0), // refused handshakes
+	}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+// get makes a request on a new connection and returns the server's
+// certificate. A client certificate is always sent, even when the server
+// did not list its CA, so the server is the one refusing it.
+func get(url string, roots *x509.CertPool, client *tls.Certificate) (*x509.Certificate, error) {
+	cfg := &tls.Config{RootCAs: roots}
+	if client != nil {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return client, nil }
+	}
+	c := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+	res, err := c.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return res.TLS.PeerCertificates[0], nil
+}
+
+func TestTLSReloadsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	ca := newTestCert(t, "ca", nil, 0)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	newTestCert(t, "one", ca, x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+
+	cfg, store, err := newTLSConfig("client", config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"}, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.watch(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(store.close)
+	url := serveTLS(t, cfg)
+
+	if c, err := get(url, roots, nil); err != nil || c.Subject.CommonName != "one" {
+		t.Fatalf("certificate = %v, %v, want one", c, err)
+	}
+
+	// a broken pair is skipped and the served certificate kept
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.load(); err == nil {
+		t.Fatal("broken key pair loaded")
+	}
+	if c, err := get(url, roots, nil); err != nil || c.Subject.CommonName != "one" {
+		t.Fatalf("certificate = %v, %v, want one kept", c, err)
+	}
+
+	newTestCert(t, "two", ca, x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		c, err := get(url, roots, nil)
+		if err == nil && c.Subject.CommonName == "two" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("certificate = %v, %v, want two after the files changed", c, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	ca := newTestCert(t, "ca", nil, 0)
+	ca.write(t, caFile, "")
+	newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	operator := newTestCert(t, "operator", ca, x509.ExtKeyUsageClientAuth).tls()
+	stranger := newTestCert(t, "stranger", newTestCert(t, "other ca", nil, 0), x509.ExtKeyUsageClientAuth).tls()
+
+	tests := []struct {
+		clientAuth string
+		client     *tls.Certificate
+		ok         bool
+	}{
+		{"require", &operator, true},
+		{"require", nil, false},
+		{"require", &stranger, false},
+		{"optional", nil, true},
+		{"optional", &operator, true},
+		{"optional", &stranger, false},
+	}
+	for _, tt := range tests {
+		name := tt.clientAuth + "/none"
+		if tt.client != nil {
+			name = tt.clientAuth + "/" + tt.client.Leaf.Subject.CommonName
+		}
+		t.Run(name, func(t *testing.T) {
+			cfg, _, err := newTLSConfig("console", config.TLSConfig{
+				Enabled: true, CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: tt.clientAuth,
+			}, "prod")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = get(serveTLS(t, cfg), roots, tt.client)
+			if (err == nil) != tt.ok {
+				t.Fatalf("request err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTLSDevCertificate(t *testing.T) {
+	if _, _, err := newTLSConfig("client", config.TLSConfig{Enabled: true}, "prod"); err == nil {
+		t.Fatal("no certFile accepted outside dev")
+	}
+	cfg, _, err := newTLSConfig("client", config.TLSConfig{Enabled: true}, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serveTLS(t, cfg)
+	if _, err := get(url, nil, nil); err == nil {
+		t.Fatal("self-signed certificate trusted")
+	}
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	res, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if cn := res.TLS.PeerCertificates[0].Subject.CommonName; cn != "localhost" {
+		t.Fatalf("dev certificate CN = %q, want localhost", cn)
+	}
+}
@@ -0,0 +1,168 @@
+// Package apperr is the catalog of application errors returned to clients.
+// Every error has a stable code ("<domain>.<name>", e.g. user.not_found), an
+// HTTP status and a user-facing message; the wrapped cause is internal and
+// only ever logged (see response.Fail).
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Error is a catalogued application error. Registered values are templates:
+// the With* methods return copies, so they are safe to share.
+type Error struct {
+	Code    string `json:"code"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+	Cause   error  `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Cause }
+
+// Is matches any *Error with the same code, so errors.Is(err, apperr.NotFound)
+// holds for NotFound.WithCause(...) too.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithCause returns a copy wrapping the internal cause.
+func (e *Error) WithCause(err error) *Error {
+	c := *e
+	c.Cause = err
+	return &c
+}
+
+// WithMessage returns a copy with a different user-facing message.
+func (e *Error) WithMessage(msg string) *Error {
+	c := *e
+	c.Message = msg
+	return &c
+}
+
+// WithDetails returns a copy carrying structured details for the client.
+func (e *Error) WithDetails(details any) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+type mapping struct {
+	sentinel error
+	err      *Error
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]*Error{}
+	mappings []mapping
+)
+
+// Register adds a code to the catalog, e.g.
+//
+//	var ErrOrderClosed = apperr.Register("order", "closed", http.StatusConflict, "order is closed")
+//
+// It panics on a duplicate code so two domains can't claim the same one.
+func Register(domain, name string, status int, message string) *Error {
+	e := &Error{Code: domain + "." + name, Status: status, Message: message}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[e.Code]; ok {
+		panic("apperr: duplicate code " + e.Code)
+	}
+	registry[e.Code] = e
+	return e
+}
+
+// Map makes From translate errors matching sentinel (errors.Is) into e.
+// Mappings are tried in registration order.
+func Map(sentinel error, e *Error) {
+	mu.Lock()
+	defer mu.Unlock()
+	mappings = append(mappings, mapping{sentinel: sentinel, err: e})
+}
+
+// From converts any error into an *Error: an *Error in the chain is
+// returned as is, a mapped sentinel becomes its catalog entry with err as
+// the cause, and everything else is Internal.
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, m := range mappings {
+		if errors.Is(err, m.sentinel) {
+			return m.err.WithCause(err)
+		}
+	}
+	return Internal.WithCause(err)
+}
+
+// Catalog lists every registered code, sorted.
+func Catalog() []Error {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]Error, 0, len(registry))
+	for _, e := range registry {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
+
+// Common codes shared by every domain.
+var (
+	BadRequest       = Register("common", "bad_request", http.StatusBadRequest, "bad request")
+	Unauthorized     = Register("common", "unauthorized", http.StatusUnauthorized, "unauthorized")
+	Forbidden        = Register("common", "forbidden", http.StatusForbidden, "forbidden")
+	NotFound         = Register("common", "not_found", http.StatusNotFound, "resource not found")
+	Conflict         = Register("common", "conflict", http.StatusConflict, "conflict")
+	UnsupportedMedia = Register("common", "unsupported_media_type", http.StatusUnsupportedMediaType, "unsupported content type")
+	PayloadTooLarge  = Register("common", "payload_too_large", http.StatusRequestEntityTooLarge, "request body too large")
+	TooManyRequests  = Register("common", "too_many_requests", http.StatusTooManyRequests, "too many requests")
+	Internal         = Register("common", "internal", http.StatusInternalServerError, "internal server error")
+	Unavailable      = Register("common", "unavailable", http.StatusServiceUnavailable, "service unavailable")
+	Timeout          = Register("common", "timeout", http.StatusGatewayTimeout, "request timed out")
+)
+
+func init() {
+	Map(gorm.ErrRecordNotFound, NotFound)
+	Map(gorm.ErrDuplicatedKey, Conflict)
+}
+
+// ForStatus returns the common error for an HTTP status, for callers that
+// only know the status. Unknown statuses keep their value under the
+// bad_request (4xx) or internal (5xx) code.
+func ForStatus(status int) *Error {
+	for _, e := range []*Error{BadRequest, Unauthorized, Forbidden, NotFound, Conflict, UnsupportedMedia, PayloadTooLarge, TooManyRequests, Internal, Unavailable, Timeout} {
+		if e.Status == status {
+			return e
+		}
+	}
+	c := *Internal
+	if status < http.StatusInternalServerError {
+		c = *BadRequest
+	}
+	c.Status = status
+	c.Message = http.StatusText(status)
+	return &c
+}
@@ -0,0 +1,148 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+var (
+	errOrderClosed  = errors.New("order closed")
+	codeOrderClosed = Register("order", "closed", http.StatusConflict, "order is closed")
+)
+
+func init() {
+	Map(errOrderClosed, codeOrderClosed)
+}
+
+func TestFrom(t *testing.T) {
+	leak := errors.New("dial tcp 10.0.0.5:5432: password authentication failed")
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   string
+		wantStatus int
+		wantMsg    string
+		wantCause  error // errors.Is on the result
+	}{
+		{
+			name:     "catalog error as is",
+			err:      NotFound,
+			wantCode: "common.not_found", wantStatus: http.StatusNotFound, wantMsg: "resource not found",
+		},
+		{
+			name:     "wrapped catalog error",
+			err:      fmt.Errorf("load order: %w", codeOrderClosed.WithMessage("order 7 is closed")),
+			wantCode: "order.closed", wantStatus: http.StatusConflict, wantMsg: "order 7 is closed",
+		},
+		{
+			name:     "mapped sentinel",
+			err:      errOrderClosed,
+			wantCode: "order.closed", wantStatus: http.StatusConflict, wantMsg: "order is closed",
+			wantCause: errOrderClosed,
+		},
+		{
+			name:     "wrapped mapped sentinel",
+			err:      fmt.Errorf("pay: %w", errOrderClosed),
+			wantCode: "order.closed", wantStatus: http.StatusConflict, wantMsg: "order is closed",
+			wantCause: errOrderClosed,
+		},
+		{
+			name:     "gorm not found",
+			err:      fmt.Errorf("get user: %w", gorm.ErrRecordNotFound),
+			wantCode: "common.not_found", wantStatus: http.StatusNotFound, wantMsg: "resource not found",
+			wantCause: gorm.ErrRecordNotFound,
+		},
+		{
+			name:     "gorm duplicate key",
+			err:      gorm.ErrDuplicatedKey,
+			wantCode: "common.conflict", wantStatus: http.StatusConflict, wantMsg: "conflict",
+			wantCause: gorm.ErrDuplicatedKey,
+		},
+		{
+			name:     "unknown error",
+			err:      leak,
+			wantCode: "common.internal", wantStatus: http.StatusInternalServerError, wantMsg: "internal server error",
+			wantCause: leak,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := From(tt.err)
+			if e.Code != tt.wantCode || e.Status != tt.wantStatus || e.Message != tt.wantMsg {
+				t.Fatalf("From = %s %d %q, want %s %d %q", e.Code, e.Status, e.Message, tt.wantCode, tt.wantStatus, tt.wantMsg)
+			}
+			if tt.wantCause != nil && !errors.Is(e.Cause, tt.wantCause) {
+				t.Fatalf("cause = %v, want %v", e.Cause, tt.wantCause)
+			}
+			if strings.Contains(e.Message, "password") {
+				t.Fatalf("message %q leaks the cause", e.Message)
+			}
+		})
+	}
+	if From(nil) != nil {
+		t.Fatal("From(nil) != nil")
+	}
+}
+
+func TestFromLeavesTemplatesAlone(t *testing.T) {
+	_ = From(errOrderClosed)
+	_ = codeOrderClosed.WithMessage("changed").WithDetails("x")
+	if codeOrderClosed.Cause != nil || codeOrderClosed.Message != "order is closed" || codeOrderClosed.Details != nil {
+		t.Fatalf("template changed: %+v", codeOrderClosed)
+	}
+	if !errors.Is(codeOrderClosed.WithCause(errOrderClosed), codeOrderClosed) {
+		t.Fatal("errors.Is does not match a copy by code")
+	}
+	if errors.Is(codeOrderClosed, Conflict) {
+		t.Fatal("errors.Is matches another code of the same status")
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate code registered")
+		}
+	}()
+	Register("order", "closed", http.StatusGone, "again")
+}
+
+func TestCatalog(t *testing.T) {
+	cat := Catalog()
+	if !slices.IsSortedFunc(cat, func(a, b Error) int { return strings.Compare(a.Code, b.Code) }) {
+		t.Fatal("catalog not sorted")
+	}
+	for _, code := range []string{"common.internal", "common.not_found", "order.closed"} {
+		if !slices.ContainsFunc(cat, func(e Error) bool { return e.Code == code }) {
+			t.Fatalf("catalog misses %s", code)
+		}
+	}
+}
+
+func TestForStatus(t *testing.T) {
+	tests := []struct {
+		status   int
+		wantCode string
+		wantMsg  string
+	}{
+		{http.StatusNotFound, "common.not_found", "resource not found"},
+		{http.StatusGatewayTimeout, "common.timeout", "request timed out"},
+		{http.StatusTeapot, "common.bad_request", "I'm a teapot"},
+		{http.StatusBadGateway, "common.internal", "Bad Gateway"},
+	}
+	for _, tt := range tests {
+		e := ForStatus(tt.status)
+		if e.Status != tt.status || e.Code != tt.wantCode || e.Message != tt.wantMsg {
+			t.Errorf("ForStatus(%d) = %s %d %q, want %s %q", tt.status, e.Code, e.Status, e.Message, tt.wantCode, tt.wantMsg)
+		}
+	}
+	if BadRequest.Status != http.StatusBadRequest || Internal.Status != http.StatusInternalServerError {
+		t.Fatal("ForStatus changed a template")
+	}
+}
@@ -0,0 +1,109 @@
+// Package binding fills request params with paramHelper and validates them,
+// answering the request itself when that fails:
+//
+//	var req dto.LoginRequest
+//	if !binding.Bind(c, &req, networkStruct.BodyJson) {
+//		return
+//	}
+package binding
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wiidz/goutil/helpers/paramHelper"
+	"github.com/wiidz/goutil/structs/networkStruct"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+	"github.com/wiidz/gin_template/internal/common/response"
+)
+
+// Bind fills params from the request and validates them. On failure it
+// responds through response.Fail (validation failures with field details,
+// see Validate) and returns false.
+//
+// With networkStruct.BodyJson the body may also be MessagePack or XML, as
+// named by Content-Type, when the port allows that format (see
+// response.Formats); other allowed-format mismatches get 415.
+func Bind(c *gin.Context, params networkStruct.ParamsInterface, contentType networkStruct.ContentType) bool {
+	if contentType == networkStruct.BodyJson {
+		if err := transcodeBody(c, reflect.TypeOf(params)); err != nil {
+			response.Fail(c, err)
+			return false
+		}
+	}
+	// paramHelper's own validation only reports the first failure as
+	// translated text, so it is skipped in favour of Validate.
+	if err := paramHelper.BuildParams(c.Request, params, contentType, paramHelper.WithSkipValidation()); err != nil {
+		response.Fail(c, apperr.BadRequest.WithMessage("malformed request parameters").WithCause(err))
+		return false
+	}
+	if err := Validate(c, params); err != nil {
+		response.Fail(c, err)
+		return false
+	}
+	return true
+}
+
+// BindProto decodes a protobuf body (application/x-protobuf) or its JSON
+// mapping into m. The port must allow protobuf.
+func BindProto(c *gin.Context, m proto.Message) bool {
+	if !response.Allowed(c, response.FormatProtobuf) {
+		response.Fail(c, apperr.UnsupportedMedia)
+		return false
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err == nil {
+		if bodyFormat(c) == response.FormatProtobuf {
+			err = proto.Unmarshal(body, m)
+		} else {
+			err = protojson.Unmarshal(body, m)
+		}
+	}
+	if err != nil {
+		response.Fail(c, apperr.BadRequest.WithMessage("malformed request body").WithCause(err))
+		return false
+	}
+	return true
+}
+
+// bodyFormat is the format named by Content-Type; JSON when it is missing
+// or unknown, which is what paramHelper assumed before.
+func bodyFormat(c *gin.Context) string {
+	mt, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if f := response.FormatOf(mt); f != "" {
+		return f
+	}
+	return response.FormatJSON
+}
+
+// transcodeBody replaces a MessagePack or XML body with its JSON form.
+func transcodeBody(c *gin.Context, target reflect.Type) error {
+	f := bodyFormat(c)
+	if f == response.FormatJSON {
+		return nil
+	}
+	if !response.Allowed(c, f) || f == response.FormatProtobuf {
+		return apperr.UnsupportedMedia
+	}
+	var (
+		body []byte
+		err  error
+	)
+	if f == response.FormatMsgPack {
+		body, err = msgpackToJSON(c.Request.Body)
+	} else {
+		body, err = xmlToJSON(c.Request.Body, target)
+	}
+	if err != nil {
+		return apperr.BadRequest.WithMessage("malformed request body").WithCause(err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+	return nil
+}
@@ -0,0 +1,168 @@
+package binding
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+)
+
+// paramHelper only reads JSON bodies, so MessagePack and XML bodies are
+// transcoded to JSON first; the params then see the same raw map (and
+// defaults, PATCH presence, ...) as for a JSON request.
+
+func msgpackToJSON(r io.Reader) ([]byte, error) {
+	h := &codec.MsgpackHandle{}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]any(nil))
+	var v map[string]any
+	if err := codec.NewDecoder(r, h).Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// xmlToJSON reads a document such as
+//
+//	<request><login_id>a</login_id><tags>x</tags><tags>y</tags></request>
+//
+// ignoring the root element's name. XML has no types, so target (the
+// params struct) decides which elements are numbers, booleans or lists.
+func xmlToJSON(r io.Reader, target reflect.Type) ([]byte, error) {
+	root, err := parseXML(xml.NewDecoder(r))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(root.value(target))
+}
+
+type xmlNode struct {
+	name     string
+	text     string
+	children []*xmlNode
+}
+
+func parseXML(d *xml.Decoder) (*xmlNode, error) {
+	var stack []*xmlNode
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, errors.New("xml: no root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name.Local}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return n, nil
+			}
+		}
+	}
+}
+
+func (n *xmlNode) value(t reflect.Type) any {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if len(n.children) == 0 {
+		return scalar(strings.TrimSpace(n.text), t)
+	}
+	if t != nil && t.Kind() == reflect.Slice {
+		out := make([]any, 0, len(n.children))
+		for _, c := range n.children {
+			out = append(out, c.value(t.Elem()))
+		}
+		return out
+	}
+	out := make(map[string]any, len(n.children))
+	for _, c := range n.children {
+		ft := fieldType(t, c.name)
+		if ft != nil && ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8 && !isWrapper(c, ft) {
+			// one item of a repeated element: <tags>x</tags><tags>y</tags>
+			prev, _ := out[c.name].([]any)
+			out[c.name] = append(prev, c.value(ft.Elem()))
+			continue
+		}
+		out[c.name] = c.value(ft)
+	}
+	return out
+}
+
+// isWrapper reports whether n holds a whole list of scalars
+// (<tags><v>x</v><v>y</v></tags>) rather than being one item of it.
+func isWrapper(n *xmlNode, list reflect.Type) bool {
+	elem := list.Elem()
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	return len(n.children) > 0 && elem.Kind() != reflect.Struct && elem.Kind() != reflect.Map
+}
+
+// fieldType finds the struct field named name (as binding names fields).
+func fieldType(t reflect.Type, name string) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if ft := fieldType(f.Type, name); ft != nil {
+				return ft
+			}
+			continue
+		}
+		if fieldName(f) == name {
+			return f.Type
+		}
+	}
+	return nil
+}
+
+// scalar converts s to t's kind when it parses, leaving it a string
+// otherwise so decoding reports the mismatch.
+func scalar(s string, t reflect.Type) any {
+	if t == nil {
+		return s
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
@@ -0,0 +1,194 @@
+package binding
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+	"github.com/wiidz/goutil/structs/networkStruct"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+	"github.com/wiidz/gin_template/internal/common/response"
+)
+
+type profileRequest struct {
+	networkStruct.Params
+
+	Name  string   `json:"name" belong:"value" validate:"required"`
+	Age   int      `json:"age" belong:"value"`
+	Admin bool     `json:"admin" belong:"value"`
+	Tags  []string `json:"tags" belong:"value"`
+}
+
+func msgpackOf(t *testing.T, v any) []byte {
+	t.Helper()
+	var b []byte
+	if err := codec.NewEncoderBytes(&b, &codec.MsgpackHandle{}).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// errorCode returns the code of an error response, or "".
+func errorCode(body []byte) string {
+	var res struct {
+		Code string `json:"code"`
+	}
+	_ = json.Unmarshal(body, &res)
+	return res.Code
+}
+
+func TestBindBodyFormats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	want := profileRequest{Name: "alice", Age: 30, Admin: true, Tags: []string{"x", "y"}}
+	all := []string{response.FormatMsgPack, response.FormatXML}
+	tests := []struct {
+		name        string
+		formats     []string
+		contentType string
+		body        []byte
+		wantStatus  int
+		wantCode    string
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        []byte(`{"name":"alice","age":30,"admin":true,"tags":["x","y"]}`),
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "msgpack",
+			formats:     all,
+			contentType: "application/msgpack",
+			body:        msgpackOf(t, map[string]any{"name": "alice", "age": 30, "admin": true, "tags": []string{"x", "y"}}),
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "xml with repeated elements",
+			formats:     all,
+			contentType: "application/xml; charset=utf-8",
+			body:        []byte(`<request><name>alice</name><age>30</age><admin>true</admin><tags>x</tags><tags>y</tags></request>`),
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "xml with a list wrapper",
+			formats:     all,
+			contentType: "text/xml",
+			body:        []byte(`<profile><name>alice</name><age>30</age><admin>1</admin><tags><v>x</v><v>y</v></tags></profile>`),
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "msgpack not allowed",
+			formats:     []string{response.FormatXML},
+			contentType: "application/x-msgpack",
+			body:        msgpackOf(t, map[string]any{"name": "alice"}),
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    apperr.UnsupportedMedia.Code,
+		},
+		{
+			name:        "protobuf for params",
+			formats:     []string{response.FormatProtobuf},
+			contentType: "application/x-protobuf",
+			body:        []byte{0x0a, 0x01, 0x61},
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    apperr.UnsupportedMedia.Code,
+		},
+		{
+			name:        "malformed xml",
+			formats:     all,
+			contentType: "application/xml",
+			body:        []byte(`<request><name>alice</request>`),
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    apperr.BadRequest.Code,
+		},
+		{
+			name:        "invalid msgpack params",
+			formats:     all,
+			contentType: "application/msgpack",
+			body:        msgpackOf(t, map[string]any{"age": 30}),
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    ErrValidation.Code,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got profileRequest
+			e := gin.New()
+			e.POST("/", response.Formats(tt.formats), func(c *gin.Context) {
+				if Bind(c, &got, networkStruct.BodyJson) {
+					c.Status(http.StatusOK)
+				}
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantCode != "" {
+				if code := errorCode(w.Body.Bytes()); code != tt.wantCode {
+					t.Fatalf("code = %q, want %s", code, tt.wantCode)
+				}
+				return
+			}
+			if got.Name != want.Name || got.Age != want.Age || got.Admin != want.Admin || !slices.Equal(got.Tags, want.Tags) {
+				t.Fatalf("bound %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestBindProto(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	binary, err := proto.Marshal(wrapperspb.String("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		formats     []string
+		contentType string
+		body        []byte
+		wantStatus  int
+	}{
+		{"binary", []string{response.FormatProtobuf}, "application/x-protobuf", binary, http.StatusOK},
+		{"protobuf media type", []string{response.FormatProtobuf}, "application/protobuf", binary, http.StatusOK},
+		{"json mapping", []string{response.FormatProtobuf}, "application/json", []byte(`"alice"`), http.StatusOK},
+		{"not allowed", nil, "application/x-protobuf", binary, http.StatusUnsupportedMediaType},
+		{"malformed", []string{response.FormatProtobuf}, "application/x-protobuf", []byte{0xff}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got wrapperspb.StringValue
+			e := gin.New()
+			e.POST("/", response.Formats(tt.formats), func(c *gin.Context) {
+				if BindProto(c, &got) {
+					c.Status(http.StatusOK)
+				}
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code == http.StatusOK && got.GetValue() != "alice" {
+				t.Fatalf("bound %q", got.GetValue())
+			}
+			if w.Code != http.StatusOK && !strings.Contains(w.Body.String(), `"code"`) {
+				t.Fatalf("body = %s, want an error response", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package binding
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entrans "github.com/go-playground/validator/v10/translations/en"
+	zhtrans "github.com/go-playground/validator/v10/translations/zh"
+	"golang.org/x/text/language"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+)
+
+// FieldError is one failed rule, named by the field's JSON (or form/url)
+// name; nested fields are dotted, e.g. items[0].name.
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Rule    string `json:"rule" xml:"rule"`
+	Param   string `json:"param,omitempty" xml:"param,omitempty"`
+	Message string `json:"message" xml:"message"`
+}
+
+// ErrValidation carries the []FieldError as details.
+var ErrValidation = apperr.Register("common", "validation_failed", http.StatusBadRequest, "invalid parameters")
+
+var (
+	validate = validator.New()
+	uni      *ut.UniversalTranslator
+
+	// languages lists the message languages in preference order; the first
+	// is used when Accept-Language matches none (Chinese, as before).
+	languages = []language.Tag{language.Chinese, language.English}
+	matcher   = language.NewMatcher(languages)
+)
+
+func init() {
+	validate.RegisterTagNameFunc(fieldName)
+	uni = ut.New(zh.New(), zh.New(), en.New())
+	zt, _ := uni.GetTranslator("zh")
+	et, _ := uni.GetTranslator("en")
+	if err := zhtrans.RegisterDefaultTranslations(validate, zt); err != nil {
+		panic(err)
+	}
+	if err := entrans.RegisterDefaultTranslations(validate, et); err != nil {
+		panic(err)
+	}
+}
+
+// fieldName names fields the way clients see them.
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "url"} {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// Validate checks v's validate tags. Failures come back as ErrValidation
+// with a []FieldError in English or Chinese, following Accept-Language.
+func Validate(c *gin.Context, v any) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return apperr.BadRequest.WithCause(err)
+	}
+	trans := translator(c.GetHeader("Accept-Language"))
+	fields := make([]FieldError, 0, len(ves))
+	for _, fe := range ves {
+		fields = append(fields, FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fe.Translate(trans),
+		})
+	}
+	return ErrValidation.WithMessage(fields[0].Message).WithDetails(fields)
+}
+
+// fieldPath drops the top-level struct name from the namespace:
+// LoginRequest.login_id -> login_id.
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
+
+func translator(acceptLanguage string) ut.Translator {
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	_, i, conf := matcher.Match(tags...)
+	if conf == language.No {
+		i = 0
+	}
+	base, _ := languages[i].Base()
+	t, _ := uni.GetTranslator(base.String())
+	return t
+}
@@ -0,0 +1,151 @@
+package binding
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wiidz/goutil/structs/networkStruct"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+)
+
+type signupRequest struct {
+	networkStruct.Params
+
+	LoginID string `json:"login_id" belong:"value" validate:"required"`
+	Device  string `json:"device" belong:"value" validate:"max=3"`
+}
+
+func TestBindInvalidBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           []FieldError
+	}{
+		{
+			name: "Chinese by default",
+			want: []FieldError{
+				{Field: "login_id", Rule: "required", Message: "login_id为必填字段"},
+				{Field: "device", Rule: "max", Param: "3", Message: "device长度不能超过3个字符"},
+			},
+		},
+		{
+			name:           "English",
+			acceptLanguage: "en-US,en;q=0.9",
+			want: []FieldError{
+				{Field: "login_id", Rule: "required", Message: "login_id is a required field"},
+				{Field: "device", Rule: "max", Param: "3", Message: "device must be a maximum of 3 characters in length"},
+			},
+		},
+		{
+			name:           "English as a fallback",
+			acceptLanguage: "fr, en;q=0.5",
+			want: []FieldError{
+				{Field: "login_id", Rule: "required", Message: "login_id is a required field"},
+				{Field: "device", Rule: "max", Param: "3", Message: "device must be a maximum of 3 characters in length"},
+			},
+		},
+		{
+			name:           "unsupported language",
+			acceptLanguage: "fr",
+			want: []FieldError{
+				{Field: "login_id", Rule: "required", Message: "login_id为必填字段"},
+				{Field: "device", Rule: "max", Param: "3", Message: "device长度不能超过3个字符"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			bound := false
+			e.POST("/", func(c *gin.Context) {
+				var req signupRequest
+				bound = Bind(c, &req, networkStruct.BodyJson)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"device":"tablet"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			if bound || w.Code != http.StatusBadRequest {
+				t.Fatalf("Bind = %v, status %d, want false and 400", bound, w.Code)
+			}
+			var body struct {
+				Code string       `json:"code"`
+				Msg  string       `json:"msg"`
+				Data []FieldError `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %s: %v", w.Body, err)
+			}
+			if body.Code != ErrValidation.Code || body.Msg != tt.want[0].Message {
+				t.Fatalf("code, msg = %q, %q, want %q, %q", body.Code, body.Msg, ErrValidation.Code, tt.want[0].Message)
+			}
+			if !slices.Equal(body.Data, tt.want) {
+				t.Fatalf("details = %+v, want %+v", body.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindValidBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	var got signupRequest
+	e.POST("/", func(c *gin.Context) {
+		if Bind(c, &got, networkStruct.BodyJson) {
+			c.Status(http.StatusNoContent)
+		}
+	})
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"login_id":"alice","device":"web"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || got.LoginID != "alice" || got.Device != "web" {
+		t.Fatalf("status %d, bound %+v", w.Code, got)
+	}
+}
+
+type orderItem struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type orderAddress struct {
+	City string `form:"city" validate:"required"` // form names count too
+}
+
+type orderRequest struct {
+	Items   []orderItem  `json:"items" validate:"min=1,dive"`
+	Address orderAddress `json:"address"`
+}
+
+func TestValidateFieldPaths(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	c.Request.Header.Set("Accept-Language", "en")
+
+	err := Validate(c, &orderRequest{Items: []orderItem{{Name: "a"}, {}}})
+	var e *apperr.Error
+	if !errors.As(err, &e) || e.Code != ErrValidation.Code {
+		t.Fatalf("err = %v, want %s", err, ErrValidation.Code)
+	}
+	var fields []string
+	for _, f := range e.Details.([]FieldError) {
+		fields = append(fields, f.Field)
+	}
+	if want := []string{"items[1].name", "address.city"}; !slices.Equal(fields, want) {
+		t.Fatalf("fields = %q, want %q", fields, want)
+	}
+
+	if err := Validate(c, &orderRequest{Items: []orderItem{{Name: "a"}}, Address: orderAddress{City: "x"}}); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package firewall
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/metrics"
+)
+
+// SourceAutoban holds the bans added by Observe. They live in this process
+// only: every replica bans on what it sees.
+const SourceAutoban = "autoban"
+
+// BanRule bans a client that gets MaxRetry responses with one of Statuses
+// within FindTime on the matching requests. Empty match fields match all.
+type BanRule struct {
+	Name     string
+	Ports    []string
+	Path     string // route template; a trailing * matches a prefix
+	Statuses []int
+	MaxRetry int
+	FindTime time.Duration
+}
+
+func (r *BanRule) matches(port, route string, status int) bool {
+	if !slices.Contains(r.Statuses, status) {
+		return false
+	}
+	if len(r.Ports) > 0 && !slices.Contains(r.Ports, port) {
+		return false
+	}
+	if r.Path == "" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return route != "" && strings.HasPrefix(route, prefix)
+	}
+	return route == r.Path
+}
+
+// BanPolicy configures Observe. The n-th ban of a client lasts
+// BanTime * 2^(n-1), at most MaxBanTime; a client that stays clean for
+// ForgetAfter after its last ban starts over.
+type BanPolicy struct {
+	Rules       []BanRule
+	BanTime     time.Duration
+	MaxBanTime  time.Duration
+	ForgetAfter time.Duration
+	Ignore      []netip.Prefix // never banned, e.g. health checkers
+}
+
+func (p *BanPolicy) duration(offence int) time.Duration {
+	d := p.BanTime
+	for i := 1; i < offence && d < p.MaxBanTime; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBanTime)
+}
+
+// Ban is an active automatic ban.
+type Ban struct {
+	IP      netip.Addr `json:"ip"`
+	Rule    string     `json:"rule"`
+	Offence int        `json:"offence"` // 1 for the first ban within ForgetAfter
+	Since   time.Time  `json:"since"`
+	Until   time.Time  `json:"until"`
+}
+
+type offender struct {
+	bans    int
+	lastBan time.Time
+}
+
+// banPolicy is read without autoban's lock, so that requests no rule can
+// count never wait for it.
+var banPolicy atomic.Pointer[BanPolicy]
+
+var autoban = struct {
+	sync.Mutex
+	hits      map[string][]time.Time // rule + "|" + ip -> recent matching responses
+	offenders map[netip.Addr]*offender
+	bans      map[netip.Addr]Ban
+}{
+	hits:      map[string][]time.Time{},
+	offenders: map[netip.Addr]*offender{},
+	bans:      map[netip.Addr]Ban{},
+}
+
+// SetBanPolicy replaces the policy; nil disables automatic banning. Active
+// bans are kept until they expire.
+func SetBanPolicy(p *BanPolicy) {
+	autoban.Lock()
+	defer autoban.Unlock()
+	banPolicy.Store(p)
+	clear(autoban.hits)
+}
+
+// timeNow is the clock of Observe.
+var timeNow = time.Now
+
+// Observe counts a finished request towards the ban rules and bans the
+// client when one trips. Only error responses a rule counts take the lock.
+func Observe(ctx context.Context, port, route, ip string, status int) {
+	if status < 400 {
+		return
+	}
+	p := banPolicy.Load()
+	if p == nil || !slices.ContainsFunc(p.Rules, func(r BanRule) bool { return r.matches(port, route, status) }) {
+		return
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return
+	}
+	addr = addr.Unmap()
+	for _, pr := range p.Ignore {
+		if pr.Contains(addr) {
+			return
+		}
+	}
+
+	autoban.Lock()
+	defer autoban.Unlock()
+	if banPolicy.Load() != p {
+		return // replaced meanwhile, along with the counters
+	}
+	if _, banned := autoban.bans[addr]; banned {
+		return
+	}
+	now := timeNow()
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if !r.matches(port, route, status) {
+			continue
+		}
+		key := r.Name + "|" + addr.String()
+		hits := append(autoban.hits[key], now)
+		if len(hits) > r.MaxRetry {
+			hits = hits[len(hits)-r.MaxRetry:]
+		}
+		if len(hits) < r.MaxRetry || now.Sub(hits[0]) > r.FindTime {
+			autoban.hits[key] = hits
+			continue
+		}
+		delete(autoban.hits, key)
+		banLocked(ctx, p, addr, r.Name, now)
+		return
+	}
+}
+
+func banLocked(ctx context.Context, p *BanPolicy, addr netip.Addr, rule string, now time.Time) {
+	o := autoban.offenders[addr]
+	if o == nil || now.Sub(o.lastBan) > p.ForgetAfter {
+		o = &offender{}
+		autoban.offenders[addr] = o
+	}
+	o.bans++
+	o.lastBan = now
+	b := Ban{IP: addr, Rule: rule, Offence: o.bans, Since: now, Until: now.Add(p.duration(o.bans))}
+	autoban.bans[addr] = b
+	applyBansLocked()
+
+	metrics.AutobanBans.WithLabelValues(rule).Inc()
+	logger.FromContext(ctx).Named("firewall").Warn("autoban_banned",
+		zap.String("ban_ip", addr.String()),
+		zap.String("rule", rule),
+		zap.Int("offence", b.Offence),
+		zap.Duration("duration", b.Until.Sub(b.Since)),
+		zap.Time("until", b.Until))
+}
+
+// Unban lifts the automatic ban of ip; false when there is none.
+func Unban(ctx context.Context, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	autoban.Lock()
+	defer autoban.Unlock()
+	b, ok := autoban.bans[addr.Unmap()]
+	if ok {
+		unbanLocked(ctx, b, "manual")
+		applyBansLocked()
+	}
+	return ok
+}
+
+// Bans lists the active automatic bans, soonest to expire first.
+func Bans() []Ban {
+	autoban.Lock()
+	defer autoban.Unlock()
+	out := make([]Ban, 0, len(autoban.bans))
+	for _, b := range autoban.bans {
+		out = append(out, b)
+	}
+	slices.SortFunc(out, func(a, b Ban) int { return a.Until.Compare(b.Until) })
+	return out
+}
+
+func unbanLocked(ctx context.Context, b Ban, reason string) {
+	delete(autoban.bans, b.IP)
+	metrics.AutobanUnbans.WithLabelValues(reason).Inc()
+	logger.FromContext(ctx).Named("firewall").Info("autoban_unbanned",
+		zap.String("ban_ip", b.IP.String()),
+		zap.String("rule", b.Rule),
+		zap.String("reason", reason))
+}
+
+func applyBansLocked() {
+	rules := make([]Rule, 0, len(autoban.bans))
+	for _, b := range autoban.bans {
+		rules = append(rules, Rule{
+			Action:    Deny,
+			Prefix:    netip.PrefixFrom(b.IP, b.IP.BitLen()),
+			Reason:    "autoban: " + b.Rule,
+			ExpiresAt: b.Until,
+		})
+	}
+	SetRules(SourceAutoban, rules)
+	metrics.AutobanActive.Set(float64(len(rules)))
+}
+
+// RunAutoban lifts expired bans and forgets stale counters every second
+// until ctx is done. Expired bans stop matching on their own; this is what
+// logs their end and keeps memory bounded.
+func RunAutoban(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sweep(ctx, now)
+		}
+	}
+}
+
+func sweep(ctx context.Context, now time.Time) {
+	autoban.Lock()
+	defer autoban.Unlock()
+	expired := false
+	for _, b := range autoban.bans {
+		if !now.Before(b.Until) {
+			unbanLocked(ctx, b, "expired")
+			expired = true
+		}
+	}
+	if expired {
+		applyBansLocked()
+	}
+	p := banPolicy.Load()
+	if p == nil {
+		return
+	}
+	for addr, o := range autoban.offenders {
+		if _, banned := autoban.bans[addr]; !banned && now.Sub(o.lastBan) > p.ForgetAfter {
+			delete(autoban.offenders, addr)
+		}
+	}
+	var window time.Duration
+	for _, r := range p.Rules {
+		window = max(window, r.FindTime)
+	}
+	for key, hits := range autoban.hits {
+		if now.Sub(hits[len(hits)-1]) > window {
+			delete(autoban.hits, key)
+		}
+	}
+}
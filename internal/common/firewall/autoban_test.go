@@ -0,0 +1,161 @@
+package firewall
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// useBanPolicy installs p with a fake clock and clears all autoban state
+// afterwards.
+func useBanPolicy(t *testing.T, p *BanPolicy) *time.Time {
+	t.Helper()
+	clock := time.Now()
+	timeNow = func() time.Time { return clock }
+	SetBanPolicy(p)
+	t.Cleanup(func() {
+		timeNow = time.Now
+		SetBanPolicy(nil)
+		autoban.Lock()
+		clear(autoban.offenders)
+		clear(autoban.bans)
+		applyBansLocked()
+		autoban.Unlock()
+	})
+	return &clock
+}
+
+func loginPolicy() *BanPolicy {
+	return &BanPolicy{
+		Rules: []BanRule{{
+			Name:     "login",
+			Path:     "/api/v1/auth/login",
+			Statuses: []int{401},
+			MaxRetry: 3,
+			FindTime: time.Minute,
+		}},
+		BanTime:     time.Minute,
+		MaxBanTime:  5 * time.Minute,
+		ForgetAfter: time.Hour,
+		Ignore:      []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}
+}
+
+func fail(ip string, n int) {
+	for range n {
+		Observe(context.Background(), "client", "/api/v1/auth/login", ip, 401)
+	}
+}
+
+func banOf(t *testing.T, ip string) (Ban, bool) {
+	t.Helper()
+	for _, b := range Bans() {
+		if b.IP == netip.MustParseAddr(ip) {
+			return b, true
+		}
+	}
+	return Ban{}, false
+}
+
+func TestObserveBansAfterMaxRetry(t *testing.T) {
+	clock := useBanPolicy(t, loginPolicy())
+	const ip = "203.0.113.7"
+
+	Observe(context.Background(), "client", "/api/v1/auth/login", ip, 200)
+	Observe(context.Background(), "client", "/api/v1/users", ip, 401)
+	fail(ip, 2)
+	if _, banned := banOf(t, ip); banned {
+		t.Fatal("banned before maxRetry")
+	}
+
+	// hits outside findTime do not add up
+	*clock = clock.Add(2 * time.Minute)
+	fail(ip, 2)
+	if _, banned := banOf(t, ip); banned {
+		t.Fatal("banned on hits spread over more than findTime")
+	}
+
+	fail(ip, 1)
+	b, banned := banOf(t, ip)
+	if !banned || b.Rule != "login" || b.Offence != 1 || b.Until.Sub(b.Since) != time.Minute {
+		t.Fatalf("ban = %+v, %v, want a first 1m ban by login", b, banned)
+	}
+	if Check("console", ip).Allowed {
+		t.Fatal("banned client still reaches other ports")
+	}
+
+	fail("10.1.2.3", 10)
+	fail("::ffff:10.1.2.4", 10)
+	if len(Bans()) != 1 {
+		t.Fatalf("bans = %+v, want ignored clients left alone", Bans())
+	}
+}
+
+func TestObserveEscalates(t *testing.T) {
+	clock := useBanPolicy(t, loginPolicy())
+	const ip = "203.0.113.7"
+
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		fail(ip, 3)
+		b, banned := banOf(t, ip)
+		if !banned || b.Offence != i+1 || b.Until.Sub(b.Since) != want {
+			t.Fatalf("ban %d = %+v, %v, want offence %d for %s", i+1, b, banned, i+1, want)
+		}
+		*clock = b.Until
+		sweep(context.Background(), *clock)
+		if _, banned := banOf(t, ip); banned {
+			t.Fatalf("ban %d not lifted at its end", i+1)
+		}
+	}
+}
+
+func TestObserveForgetAfter(t *testing.T) {
+	clock := useBanPolicy(t, loginPolicy())
+	const ip = "203.0.113.7"
+
+	fail(ip, 3)
+	b, _ := banOf(t, ip)
+	*clock = b.Until
+	sweep(context.Background(), *clock)
+
+	// within forgetAfter of the last ban the next one is longer
+	fail(ip, 3)
+	b, _ = banOf(t, ip)
+	if b.Offence != 2 {
+		t.Fatalf("offence = %d, want 2", b.Offence)
+	}
+
+	*clock = b.Since.Add(time.Hour + time.Second)
+	sweep(context.Background(), *clock)
+	autoban.Lock()
+	_, remembered := autoban.offenders[netip.MustParseAddr(ip)]
+	autoban.Unlock()
+	if remembered {
+		t.Fatal("offender kept past forgetAfter")
+	}
+
+	fail(ip, 3)
+	b, _ = banOf(t, ip)
+	if b.Offence != 1 || b.Until.Sub(b.Since) != time.Minute {
+		t.Fatalf("ban = %+v, want a first 1m ban after forgetAfter", b)
+	}
+}
+
+func TestSetBanPolicyResetsCounters(t *testing.T) {
+	useBanPolicy(t, loginPolicy())
+	const ip = "203.0.113.7"
+
+	fail(ip, 2)
+	SetBanPolicy(loginPolicy())
+	fail(ip, 2)
+	if _, banned := banOf(t, ip); banned {
+		t.Fatal("hits survived a policy change")
+	}
+
+	SetBanPolicy(nil)
+	fail(ip, 10)
+	if len(Bans()) != 0 {
+		t.Fatal("banned with autoban disabled")
+	}
+}
@@ -0,0 +1,164 @@
+// Package firewall decides which client IPs may reach which port. Rules are
+// IPs or CIDR blocks (IPv4 or IPv6) that deny a client, or allow it on a
+// port that has an allowlist. Rules come from several sources (config, the
+// database, ...), each replaced as a whole with SetRules.
+//
+// A request is rejected when its port (or every port) has allow rules and
+// none matches, or when any deny rule matches.
+package firewall
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Rule actions.
+const (
+	Deny  = "deny"
+	Allow = "allow"
+)
+
+// Rule sources.
+const (
+	SourceConfig   = "config"
+	SourceDatabase = "database"
+)
+
+type Rule struct {
+	ID        uint64       `json:"id,omitempty"` // set for database rules
+	Action    string       `json:"action"`
+	Prefix    netip.Prefix `json:"cidr"`
+	Port      string       `json:"port,omitempty"` // empty = every port
+	Reason    string       `json:"reason,omitempty"`
+	ExpiresAt time.Time    `json:"expires_at,omitzero"` // zero = never
+	Source    string       `json:"source"`
+}
+
+func (r Rule) active(now time.Time) bool {
+	return r.ExpiresAt.IsZero() || now.Before(r.ExpiresAt)
+}
+
+func (r Rule) appliesTo(port string) bool { return r.Port == "" || r.Port == port }
+
+// ParsePrefix accepts an IP (a single-address block) or a CIDR block.
+// IPv4-mapped IPv6 addresses are treated as IPv4.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid IP or CIDR %q", s)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP or CIDR %q", s)
+	}
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p.Masked(), nil
+}
+
+// Decision is the outcome of Check; Rule is the matching deny rule, or
+// nil when the client missed the port's allowlist.
+type Decision struct {
+	Allowed bool
+	Rule    *Rule
+}
+
+var (
+	mu      sync.Mutex
+	sources = map[string][]Rule{}
+	rules   atomic.Pointer[[]Rule]
+)
+
+// SetRules replaces the rules of one source.
+func SetRules(source string, rs []Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	tagged := make([]Rule, len(rs))
+	for i, r := range rs {
+		r.Source = source
+		tagged[i] = r
+	}
+	sources[source] = tagged
+	var all []Rule
+	for _, src := range sources {
+		all = append(all, src...)
+	}
+	// most specific first, so Check reports the narrowest matching rule
+	slices.SortStableFunc(all, func(a, b Rule) int { return b.Prefix.Bits() - a.Prefix.Bits() })
+	rules.Store(&all)
+}
+
+// Rules returns every rule that has not expired.
+func Rules() []Rule {
+	now := time.Now()
+	var out []Rule
+	for _, r := range current() {
+		if r.active(now) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func current() []Rule {
+	if p := rules.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// Check decides whether ip (as given by gin's ClientIP) may reach port.
+// Unparseable addresses are only let through when no rule applies to port.
+func Check(port, ip string) Decision {
+	addr, err := netip.ParseAddr(ip)
+	addr = addr.Unmap()
+	now := time.Now()
+	var gated, allowed bool
+	all := current()
+	for i, r := range all {
+		if !r.appliesTo(port) || !r.active(now) {
+			continue
+		}
+		if err != nil {
+			return Decision{Allowed: false}
+		}
+		switch r.Action {
+		case Deny:
+			if r.Prefix.Contains(addr) {
+				return Decision{Allowed: false, Rule: &all[i]}
+			}
+		case Allow:
+			gated = true
+			allowed = allowed || r.Prefix.Contains(addr)
+		}
+	}
+	return Decision{Allowed: !gated || allowed}
+}
+
+// Allowlisted reports whether an active allow rule applying to port
+// contains ip.
+func Allowlisted(port, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	now := time.Now()
+	for _, r := range current() {
+		if r.Action == Allow && r.appliesTo(port) && r.active(now) && r.Prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
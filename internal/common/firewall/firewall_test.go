@@ -0,0 +1,137 @@
+package firewall
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "203.0.113.7", want: "203.0.113.7/32"},
+		{in: " 203.0.113.0/24 ", want: "203.0.113.0/24"},
+		{in: "203.0.113.9/24", want: "203.0.113.0/24"}, // masked
+		{in: "2001:db8::1", want: "2001:db8::1/128"},
+		{in: "2001:db8::/32", want: "2001:db8::/32"},
+		{in: "::ffff:203.0.113.7", want: "203.0.113.7/32"},
+		{in: "::ffff:203.0.113.0/120", want: "203.0.113.0/24"},
+		{in: "::ffff:0:0/80", want: "::/80"}, // too short to be an IPv4 block
+		{in: "203.0.113.0/33", wantErr: true},
+		{in: "example.com", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePrefix(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePrefix(%q) = %v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil || got.String() != tt.want {
+				t.Fatalf("ParsePrefix(%q) = %v, %v, want %s", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func rule(action, cidr, port string) Rule {
+	return Rule{Action: action, Prefix: netip.MustParsePrefix(cidr), Port: port}
+}
+
+func TestCheck(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	expired := func(r Rule) Rule { r.ExpiresAt = past; return r }
+	expiring := func(r Rule) Rule { r.ExpiresAt = future; return r }
+
+	tests := []struct {
+		name     string
+		rules    []Rule
+		port     string
+		ip       string
+		want     bool
+		wantRule bool // the decision names a deny rule
+	}{
+		{name: "no rules", port: "client", ip: "203.0.113.7", want: true},
+		{name: "deny", rules: []Rule{rule(Deny, "203.0.113.0/24", "")}, port: "client", ip: "203.0.113.7", want: false, wantRule: true},
+		{name: "deny other range", rules: []Rule{rule(Deny, "203.0.113.0/24", "")}, port: "client", ip: "198.51.100.1", want: true},
+		{name: "deny IPv4-mapped IPv6 client", rules: []Rule{rule(Deny, "203.0.113.0/24", "")}, port: "client", ip: "::ffff:203.0.113.7", want: false, wantRule: true},
+		{name: "deny IPv6", rules: []Rule{rule(Deny, "2001:db8::/32", "")}, port: "client", ip: "2001:db8::5", want: false, wantRule: true},
+		{name: "deny on another port", rules: []Rule{rule(Deny, "203.0.113.0/24", "console")}, port: "client", ip: "203.0.113.7", want: true},
+		{name: "allowlisted", rules: []Rule{rule(Allow, "10.0.0.0/8", "console")}, port: "console", ip: "10.1.2.3", want: true},
+		{name: "not allowlisted", rules: []Rule{rule(Allow, "10.0.0.0/8", "console")}, port: "console", ip: "203.0.113.7", want: false},
+		{name: "allowlist of another port", rules: []Rule{rule(Allow, "10.0.0.0/8", "console")}, port: "client", ip: "203.0.113.7", want: true},
+		{name: "allowlisted IPv4-mapped IPv6 client", rules: []Rule{rule(Allow, "10.0.0.0/8", "console")}, port: "console", ip: "::ffff:10.1.2.3", want: true},
+		{
+			name:  "deny beats allow",
+			rules: []Rule{rule(Allow, "10.0.0.0/8", "console"), rule(Deny, "10.1.2.3/32", "")},
+			port:  "console", ip: "10.1.2.3", want: false, wantRule: true,
+		},
+		{
+			name:  "broad deny beats narrow allow",
+			rules: []Rule{rule(Allow, "10.1.2.3/32", "console"), rule(Deny, "10.0.0.0/8", "")},
+			port:  "console", ip: "10.1.2.3", want: false, wantRule: true,
+		},
+		{name: "expired deny", rules: []Rule{expired(rule(Deny, "203.0.113.0/24", ""))}, port: "client", ip: "203.0.113.7", want: true},
+		{name: "unexpired deny", rules: []Rule{expiring(rule(Deny, "203.0.113.0/24", ""))}, port: "client", ip: "203.0.113.7", want: false, wantRule: true},
+		{name: "expired allowlist no longer gates", rules: []Rule{expired(rule(Allow, "10.0.0.0/8", "console"))}, port: "console", ip: "203.0.113.7", want: true},
+		{name: "unparseable ip with rules", rules: []Rule{rule(Deny, "203.0.113.0/24", "")}, port: "client", ip: "nonsense", want: false},
+		{name: "unparseable ip without rules", port: "client", ip: "nonsense", want: true},
+	}
+	t.Cleanup(func() { SetRules(SourceConfig, nil) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetRules(SourceConfig, tt.rules)
+			d := Check(tt.port, tt.ip)
+			if d.Allowed != tt.want || (d.Rule != nil) != tt.wantRule {
+				t.Fatalf("Check(%s, %s) = %+v, want allowed=%v rule=%v", tt.port, tt.ip, d, tt.want, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestSetRulesMergesSources(t *testing.T) {
+	t.Cleanup(func() {
+		SetRules(SourceConfig, nil)
+		SetRules(SourceDatabase, nil)
+	})
+	SetRules(SourceConfig, []Rule{rule(Deny, "203.0.113.0/24", "")})
+	SetRules(SourceDatabase, []Rule{rule(Deny, "198.51.100.0/24", "")})
+	if Check("client", "203.0.113.7").Allowed || Check("client", "198.51.100.7").Allowed {
+		t.Fatal("a source's rules were dropped")
+	}
+	// replacing one source leaves the other alone
+	SetRules(SourceDatabase, nil)
+	if Check("client", "203.0.113.7").Allowed || !Check("client", "198.51.100.7").Allowed {
+		t.Fatal("SetRules did not replace only its own source")
+	}
+	if d := Check("client", "203.0.113.7"); d.Rule.Source != SourceConfig {
+		t.Fatalf("rule source = %q, want %q", d.Rule.Source, SourceConfig)
+	}
+}
+
+func TestAllowlisted(t *testing.T) {
+	t.Cleanup(func() { SetRules(SourceConfig, nil) })
+	SetRules(SourceConfig, []Rule{rule(Allow, "10.0.0.0/8", "console"), rule(Deny, "203.0.113.0/24", "")})
+	tests := []struct {
+		port, ip string
+		want     bool
+	}{
+		{"console", "10.1.2.3", true},
+		{"console", "::ffff:10.1.2.3", true},
+		{"console", "203.0.113.7", false},
+		{"client", "10.1.2.3", false},
+		{"console", "nonsense", false},
+	}
+	for _, tt := range tests {
+		if got := Allowlisted(tt.port, tt.ip); got != tt.want {
+			t.Errorf("Allowlisted(%s, %s) = %v, want %v", tt.port, tt.ip, got, tt.want)
+		}
+	}
+}
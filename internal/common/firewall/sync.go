@@ -0,0 +1,51 @@
+package firewall
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wiidz/gin_template/internal/common/logger"
+)
+
+var refreshers sync.Map // source -> chan struct{}
+
+// Sync loads the rules of source now, then every interval and on Refresh,
+// until ctx is done. A failed load keeps the previous rules. Polling is what
+// spreads changes made on one replica to the others.
+func Sync(ctx context.Context, source string, interval time.Duration, load func(context.Context) ([]Rule, error)) {
+	nudge := make(chan struct{}, 1)
+	refreshers.Store(source, nudge)
+	defer refreshers.Delete(source)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if rs, err := load(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.FromContext(ctx).Named("firewall").Warn("firewall_refresh_failed", zap.String("source", source), zap.Error(err))
+		} else {
+			SetRules(source, rs)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-nudge:
+		}
+	}
+}
+
+// Refresh makes the Sync of source reload without waiting for its interval.
+func Refresh(source string) {
+	if v, ok := refreshers.Load(source); ok {
+		select {
+		case v.(chan struct{}) <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying l. middleware.RequestLogger
+// stores one per request, seeded with the request ID, port, client IP,
+// login ID and trace ID.
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx, or L when there is none, so
+// handlers, services and repositories can log without knowing whether they
+// run inside a request.
+func FromContext(ctx context.Context) *zap.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(*zap.Logger); ok {
+			return l
+		}
+	}
+	if L == nil {
+		return zap.NewNop()
+	}
+	return L
+}
+
+// WithFields returns a copy of ctx whose logger also carries fields, e.g.
+// after a login resolves the caller.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(fields...))
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// useObserver replaces L with an observed logger for the test.
+func useObserver(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+	core, logs := observer.New(zap.DebugLevel)
+	prev := L
+	L = zap.New(core)
+	t.Cleanup(func() { L = prev })
+	return logs
+}
+
+func TestFromContext(t *testing.T) {
+	prev := L
+	L = nil
+	if FromContext(context.Background()) == nil || With() == nil {
+		t.Fatal("nil logger before Init")
+	}
+	L = prev
+
+	logs := useObserver(t)
+	var none context.Context
+	if FromContext(none) != L || FromContext(context.Background()) != L {
+		t.Fatal("context without a logger does not fall back to L")
+	}
+
+	ctx := NewContext(context.Background(), With(zap.String("rid", "r1")))
+	ctx = WithFields(ctx, zap.String("login_id", "alice"))
+	FromContext(ctx).Info("service_call")
+	FromContext(context.Background()).Info("background")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("%d entries", len(entries))
+	}
+	got := entries[0].ContextMap()
+	if got["rid"] != "r1" || got["login_id"] != "alice" {
+		t.Fatalf("fields = %v, want rid and login_id", got)
+	}
+	if len(entries[1].Context) != 0 {
+		t.Fatalf("background fields = %v, want none", entries[1].ContextMap())
+	}
+}
@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Gorm adapts the request logger to GORM, so queries issued with
+// db.WithContext(ctx) (as repoMng does) carry the request's fields. It logs
+// as the "repo" logger: every statement at debug, slow ones at warn and
+// failures at error. Bind parameters are dropped: only placeholder SQL is
+// written.
+type Gorm struct {
+	// SlowThreshold marks a query as slow; 0 disables slow-query logging.
+	SlowThreshold time.Duration
+}
+
+var _ gormlogger.Interface = Gorm{}
+
+func (g Gorm) LogMode(gormlogger.LogLevel) gormlogger.Interface { return g }
+
+func (g Gorm) Info(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).Named("repo").Info(fmt.Sprintf(msg, args...))
+}
+
+func (g Gorm) Warn(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).Named("repo").Warn(fmt.Sprintf(msg, args...))
+}
+
+func (g Gorm) Error(ctx context.Context, msg string, args ...interface{}) {
+	FromContext(ctx).Named("repo").Error(fmt.Sprintf(msg, args...))
+}
+
+func (g Gorm) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	lvl, msg := zapcore.DebugLevel, "db_query"
+	switch {
+	case failed:
+		lvl, msg = zapcore.ErrorLevel, "db_error"
+	case g.SlowThreshold > 0 && elapsed > g.SlowThreshold:
+		lvl, msg = zapcore.WarnLevel, "db_slow_query"
+	}
+	ce := FromContext(ctx).Named("repo").Check(lvl, msg)
+	if ce == nil {
+		return
+	}
+
+	sql, rows := fc()
+	fields := []zap.Field{
+		zap.String("sql", sql),
+		zap.Int64("rows", rows),
+		zap.Duration("elapsed", elapsed),
+	}
+	if failed {
+		fields = append(fields, zap.Error(err))
+	}
+	ce.Write(fields...)
+}
+
+// ParamsFilter keeps bind values (passwords, tokens, ...) out of the log.
+func (g Gorm) ParamsFilter(_ context.Context, sql string, _ ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
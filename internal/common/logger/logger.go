@@ -1,39 +1,193 @@
 package logger
 
 import (
-	"github.com/wiidz/goutil/helpers/loggerHelper"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/wiidz/goutil/helpers/loggerHelper"
 )
 
 var L *zap.Logger
 
-func Init(env string) {
-	level := zapcore.InfoLevel
-	if env == "dev" || env == "development" {
-		level = zapcore.DebugLevel
-	} else if env == "prod" || env == "production" {
-		level = zapcore.InfoLevel
-	}
-
-	helper, err := loggerHelper.NewLoggerHelper(&loggerHelper.Config{
-		Filename:        "", // default console only; wire to file later if needed
-		Level:           level,
-		Json:            false,
-		SyncToConsole:   true,
-		EncodeTime:      loggerHelper.MyTimeEncoder,
-		IsFullPath:      false,
-		AddCaller:       false,
-		ShowFileAndLine: false,
-		MaxSize:         10,
-		MaxBackups:      3,
-		MaxAge:          28,
-		Compress:        true,
-	})
+// Options mirrors the log section of the config. The zero value writes
+// colored text to stdout at the env's default level.
+type Options struct {
+	Env    string
+	Level  string            // "" = debug in dev, info elsewhere
+	Levels map[string]string // per named logger, e.g. access: warn, repo: debug
+	Format string            // json | console; "" = console in dev, json elsewhere
+	File   string            // "" = stdout only
+	Stdout bool              // also write to stdout when File is set
+
+	// Rotation of File, see lumberjack.Logger.
+	MaxSize    int // megabytes
+	MaxBackups int
+	MaxAge     int // days
+	Compress   bool
+
+	Caller bool // add file:line of the call site
+	// Sampling keeps the first Initial entries with the same level and
+	// message each second, then every Thereafter-th. Zero disables it.
+	SamplingInitial    int
+	SamplingThereafter int
+}
+
+// levels gates every entry of L by logger name; it is swapped as a whole so
+// it can be changed while running.
+type levels struct {
+	root  zapcore.Level
+	named map[string]zapcore.Level
+	min   zapcore.Level
+}
+
+var (
+	state        atomic.Pointer[levels]
+	defaultLevel = zapcore.InfoLevel
+)
+
+func init() {
+	state.Store(&levels{root: zapcore.InfoLevel, min: zapcore.InfoLevel})
+}
+
+// Init builds L from opts. Named loggers (L.Named("access"), ...) are
+// filtered by opts.Levels, everything else by opts.Level.
+func Init(opts Options) error {
+	dev := opts.Env == "dev" || opts.Env == "development"
+	defaultLevel = zapcore.InfoLevel
+	if dev {
+		defaultLevel = zapcore.DebugLevel
+	}
+	root, err := parseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	named, err := parseLevels(opts.Levels)
+	if err != nil {
+		return err
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = "json"
+		if dev {
+			format = "console"
+		}
+	}
+
+	var cores []zapcore.Core
+	if opts.File != "" {
+		w := &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSize,
+			MaxBackups: opts.MaxBackups,
+			MaxAge:     opts.MaxAge,
+			Compress:   opts.Compress,
+		}
+		cores = append(cores, zapcore.NewCore(encoder(format, false), zapcore.AddSync(w), zapcore.DebugLevel))
+	}
+	if opts.File == "" || opts.Stdout {
+		cores = append(cores, zapcore.NewCore(encoder(format, true), zapcore.Lock(os.Stdout), zapcore.DebugLevel))
+	}
+	core := zapcore.NewTee(cores...)
+	if opts.SamplingInitial > 0 || opts.SamplingThereafter > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, opts.SamplingInitial, opts.SamplingThereafter)
+	}
+
+	var zopts []zap.Option
+	if opts.Caller {
+		zopts = append(zopts, zap.AddCaller())
+	}
+	storeLevels(root, named)
+	L = zap.New(levelCore{Core: core}, zopts...)
+	return nil
+}
+
+func encoder(format string, stdout bool) zapcore.Encoder {
+	cfg := zapcore.EncoderConfig{
+		TimeKey:        "ts",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	if format == "json" {
+		return zapcore.NewJSONEncoder(cfg)
+	}
+	cfg.EncodeLevel = zapcore.CapitalLevelEncoder
+	if stdout {
+		cfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+	cfg.EncodeTime = loggerHelper.MyTimeEncoder
+	return zapcore.NewConsoleEncoder(cfg)
+}
+
+// SetLevel changes the level of L at runtime. An empty name restores the
+// default for the env passed to Init.
+func SetLevel(name string) error {
+	return SetLoggerLevel("", name)
+}
+
+// SetLoggerLevel changes the level of one named logger (and its children)
+// at runtime; an empty logger means the root level. An empty level removes
+// the logger's override, or restores the env default for the root.
+func SetLoggerLevel(logger, level string) error {
+	lvl, err := parseLevel(level)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	cur := state.Load()
+	named := make(map[string]zapcore.Level, len(cur.named)+1)
+	for k, v := range cur.named {
+		named[k] = v
+	}
+	root := cur.root
+	switch {
+	case logger == "":
+		root = lvl
+	case level == "":
+		delete(named, logger)
+	default:
+		named[logger] = lvl
+	}
+	storeLevels(root, named)
+	return nil
+}
+
+// SetLevels replaces every per-logger level, keeping the root level.
+func SetLevels(byName map[string]string) error {
+	named, err := parseLevels(byName)
+	if err != nil {
+		return err
+	}
+	storeLevels(state.Load().root, named)
+	return nil
+}
+
+// Level reports the current root level of L.
+func Level() zapcore.Level { return state.Load().root }
+
+// Levels reports the current per-logger levels.
+func Levels() map[string]zapcore.Level {
+	cur := state.Load()
+	out := make(map[string]zapcore.Level, len(cur.named))
+	for k, v := range cur.named {
+		out[k] = v
 	}
-	L = helper.Normal
+	return out
 }
 
 func Sync() {
@@ -48,3 +202,77 @@ func With(fields ...zap.Field) *zap.Logger {
 	}
 	return L.With(fields...)
 }
+
+func parseLevel(name string) (zapcore.Level, error) {
+	if name == "" {
+		return defaultLevel, nil
+	}
+	lvl, err := zapcore.ParseLevel(name)
+	if err != nil {
+		return 0, fmt.Errorf("logger: %w", err)
+	}
+	return lvl, nil
+}
+
+func parseLevels(byName map[string]string) (map[string]zapcore.Level, error) {
+	named := make(map[string]zapcore.Level, len(byName))
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lvl, err := zapcore.ParseLevel(byName[name])
+		if err != nil {
+			return nil, fmt.Errorf("logger: %s: %w", name, err)
+		}
+		named[name] = lvl
+	}
+	return named, nil
+}
+
+func storeLevels(root zapcore.Level, named map[string]zapcore.Level) {
+	l := &levels{root: root, named: named, min: root}
+	for _, lvl := range named {
+		if lvl < l.min {
+			l.min = lvl
+		}
+	}
+	state.Store(l)
+}
+
+// enabled looks up the most specific level for a logger name: "repo.user"
+// falls back to "repo", then to the root level.
+func (l *levels) enabled(name string, lvl zapcore.Level) bool {
+	for name != "" {
+		if min, ok := l.named[name]; ok {
+			return lvl >= min
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return lvl >= l.root
+}
+
+// levelCore filters a core built at debug through the current levels.
+type levelCore struct {
+	zapcore.Core
+}
+
+func (c levelCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= state.Load().min && c.Core.Enabled(lvl)
+}
+
+func (c levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelCore{Core: c.Core.With(fields)}
+}
+
+func (c levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !state.Load().enabled(ent.LoggerName, ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// initFile runs Init writing JSON to a temporary file and returns a function
+// reading back the entries logged so far. L and the levels are restored
+// afterwards.
+func initFile(t *testing.T, opts Options) func() []map[string]any {
+	t.Helper()
+	prevL, prevState, prevDefault := L, state.Load(), defaultLevel
+	t.Cleanup(func() { L, defaultLevel = prevL, prevDefault; state.Store(prevState) })
+
+	opts.File = filepath.Join(t.TempDir(), "app.log")
+	opts.Format = "json"
+	if err := Init(opts); err != nil {
+		t.Fatal(err)
+	}
+	return func() []map[string]any {
+		t.Helper()
+		Sync()
+		f, err := os.Open(opts.File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		var out []map[string]any
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var entry map[string]any
+			if err := json.Unmarshal(sc.Bytes(), &entry); err != nil {
+				t.Fatalf("line %q: %v", sc.Text(), err)
+			}
+			out = append(out, entry)
+		}
+		return out
+	}
+}
+
+func messages(entries []map[string]any) []string {
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e["msg"].(string))
+	}
+	return out
+}
+
+func TestInitPerLoggerLevels(t *testing.T) {
+	read := initFile(t, Options{
+		Env:    "prod",
+		Levels: map[string]string{"access": "warn", "repo": "debug"},
+		Caller: true,
+	})
+	L.Debug("root_debug")
+	L.Info("root_info")
+	L.Named("access").Info("access_info")
+	L.Named("access").Warn("access_warn")
+	L.Named("repo").Named("user").Debug("repo_user_debug")
+	L.Named("usersvc").Debug("usersvc_debug")
+
+	entries := read()
+	if want := []string{"root_info", "access_warn", "repo_user_debug"}; !slices.Equal(messages(entries), want) {
+		t.Fatalf("logged %q, want %q", messages(entries), want)
+	}
+	last := entries[2]
+	if last["level"] != "debug" || last["logger"] != "repo.user" || last["ts"] == nil {
+		t.Fatalf("entry = %v, want a JSON entry with level, logger and ts", last)
+	}
+	if caller, _ := last["caller"].(string); !strings.HasPrefix(caller, "logger/logger_test.go:") {
+		t.Fatalf("caller = %q", caller)
+	}
+}
+
+func TestSetLoggerLevel(t *testing.T) {
+	read := initFile(t, Options{Env: "prod", Level: "warn", Levels: map[string]string{"repo": "debug"}})
+
+	if err := SetLoggerLevel("access", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLoggerLevel("repo", ""); err != nil { // back to the root level
+		t.Fatal(err)
+	}
+	if err := SetLevel(""); err != nil { // the env default, info in prod
+		t.Fatal(err)
+	}
+	if err := SetLoggerLevel("repo", "loud"); err == nil {
+		t.Fatal("unknown level accepted")
+	}
+	if Level() != zapcore.InfoLevel || !maps.Equal(Levels(), map[string]zapcore.Level{"access": zapcore.DebugLevel}) {
+		t.Fatalf("levels = %s %v", Level(), Levels())
+	}
+
+	L.Named("access").Debug("access_debug")
+	L.Named("repo").Debug("repo_debug")
+	L.Info("root_info")
+	if want := []string{"access_debug", "root_info"}; !slices.Equal(messages(read()), want) {
+		t.Fatalf("logged %q, want %q", messages(read()), want)
+	}
+
+	if err := SetLevels(map[string]string{"repo": "error"}); err != nil {
+		t.Fatal(err)
+	}
+	if Level() != zapcore.InfoLevel || !maps.Equal(Levels(), map[string]zapcore.Level{"repo": zapcore.ErrorLevel}) {
+		t.Fatalf("SetLevels: levels = %s %v, want the root kept and the overrides replaced", Level(), Levels())
+	}
+}
+
+func TestInitSampling(t *testing.T) {
+	read := initFile(t, Options{Env: "prod", SamplingInitial: 2, SamplingThereafter: 3})
+	for range 8 {
+		L.Info("same")
+	}
+	L.Info("other")
+	// the 1st, 2nd, 5th and 8th of a message each second
+	if got := messages(read()); !slices.Equal(got, []string{"same", "same", "same", "same", "other"}) {
+		t.Fatalf("logged %q", got)
+	}
+}
+
+func TestInitRejectsUnknownLevels(t *testing.T) {
+	prevL, prevState, prevDefault := L, state.Load(), defaultLevel
+	t.Cleanup(func() { L, defaultLevel = prevL, prevDefault; state.Store(prevState) })
+	if err := Init(Options{Level: "loud"}); err == nil {
+		t.Fatal("unknown root level accepted")
+	}
+	if err := Init(Options{Levels: map[string]string{"repo": "loud"}}); err == nil {
+		t.Fatal("unknown logger level accepted")
+	}
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric of the process. A private registry keeps
+// metrics from imported libraries off /metrics unless registered here.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by port, method, route template and status class.",
+	}, []string{"port", "method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by port, method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"port", "method", "route"})
+
+	HTTPInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served, by port.",
+	}, []string{"port"})
+
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_rate_limit_rejections_total",
+		Help: "Requests rejected with 429, by port and limiter (global, ip).",
+	}, []string{"port", "limiter"})
+
+	RateLimitStoreErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "rate_limit_store_errors_total",
+		Help: "Times the shared rate limit store failed and limiting fell back to local.",
+	})
+
+	RateLimitStoreDegraded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "rate_limit_store_degraded",
+		Help: "1 while rate limiting runs on the local fallback store.",
+	})
+
+	FirewallRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_firewall_rejections_total",
+		Help: "Requests rejected by the firewall, by port and list (denylist, allowlist).",
+	}, []string{"port", "list"})
+
+	AutobanBans = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "firewall_autoban_bans_total",
+		Help: "Automatic bans, by ban rule.",
+	}, []string{"rule"})
+
+	AutobanUnbans = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "firewall_autoban_unbans_total",
+		Help: "Automatic bans lifted, by reason (expired, manual).",
+	}, []string{"reason"})
+
+	AutobanActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "firewall_autoban_active",
+		Help: "Automatic bans currently in force in this process.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		HTTPInFlight,
+		RateLimitRejections,
+		RateLimitStoreErrors,
+		RateLimitStoreDegraded,
+		FirewallRejections,
+		AutobanBans,
+		AutobanUnbans,
+		AutobanActive,
+	)
+}
+
+// RegisterDB exports connection pool stats of db labeled db_name=name.
+func RegisterDB(name string, db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// StatusClass maps a status code to its class label, e.g. 404 -> "4xx".
+func StatusClass(status int) string {
+	switch {
+	case status >= 500:
+		return "5xx"
+	case status >= 400:
+		return "4xx"
+	case status >= 300:
+		return "3xx"
+	case status >= 200:
+		return "2xx"
+	default:
+		return "1xx"
+	}
+}
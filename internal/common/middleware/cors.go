@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORSPolicy is the CORS setup of a port. Empty fields take the defaults
+// below; no origins means any origin.
+type CORSPolicy struct {
+	AllowOrigins     []string // exact origins, "*", or subdomain patterns like https://*.example.com
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"Origin", "Content-Type", "Accept", "Accept-Language", "Authorization", "X-Request-ID"}
+	defaultCORSExpose  = []string{"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}
+)
+
+const defaultCORSMaxAge = 12 * time.Hour
+
+// ValidateOrigin accepts "*", scheme://host[:port] and
+// scheme://*.domain[:port], the last matching any subdomain of domain.
+// It is also the config's origin rule, so both reject the same values.
+func ValidateOrigin(o string) error {
+	if o == "*" {
+		return nil
+	}
+	u, err := url.Parse(strings.Replace(o, "://*.", "://wildcard.", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" ||
+		u.User != nil || strings.ContainsAny(o, "?#") {
+		return fmt.Errorf("invalid origin %q", o)
+	}
+	if strings.Count(o, "*") > 1 || (strings.Contains(o, "*") && !strings.Contains(o, "://*.")) {
+		return fmt.Errorf("invalid origin %q: only a leading *. subdomain wildcard is supported", o)
+	}
+	return nil
+}
+
+// corsConfig builds the gin-contrib config; it rejects "*" together with
+// credentials, which browsers refuse anyway.
+func corsConfig(p CORSPolicy) (cors.Config, error) {
+	cfg := cors.Config{
+		AllowMethods:     p.AllowMethods,
+		AllowHeaders:     p.AllowHeaders,
+		ExposeHeaders:    p.ExposeHeaders,
+		AllowCredentials: p.AllowCredentials,
+		MaxAge:           p.MaxAge,
+	}
+	if len(cfg.AllowMethods) == 0 {
+		cfg.AllowMethods = defaultCORSMethods
+	}
+	if len(cfg.AllowHeaders) == 0 {
+		cfg.AllowHeaders = defaultCORSHeaders
+	}
+	if len(cfg.ExposeHeaders) == 0 {
+		cfg.ExposeHeaders = defaultCORSExpose
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = defaultCORSMaxAge
+	}
+
+	exact := map[string]bool{}
+	var patterns []subdomainPattern
+	for _, o := range p.AllowOrigins {
+		if err := ValidateOrigin(o); err != nil {
+			return cors.Config{}, err
+		}
+		switch {
+		case o == "*":
+			cfg.AllowAllOrigins = true
+		case strings.Contains(o, "://*."):
+			scheme, suffix, _ := strings.Cut(strings.ToLower(o), "://*")
+			patterns = append(patterns, subdomainPattern{prefix: scheme + "://", suffix: suffix})
+		default:
+			exact[strings.ToLower(o)] = true
+		}
+	}
+	if len(p.AllowOrigins) == 0 {
+		cfg.AllowAllOrigins = true
+	}
+	if cfg.AllowAllOrigins {
+		if p.AllowCredentials {
+			return cors.Config{}, errors.New("allowing any origin (\"*\" or no origins) cannot be combined with credentials")
+		}
+		return cfg, nil
+	}
+	cfg.AllowOriginFunc = func(origin string) bool {
+		origin = strings.ToLower(origin)
+		if exact[origin] {
+			return true
+		}
+		for _, p := range patterns {
+			if p.matches(origin) {
+				return true
+			}
+		}
+		return false
+	}
+	return cfg, nil
+}
+
+// subdomainPattern is https://*.example.com split around the *: prefix
+// "https://", suffix ".example.com" (with the port, if any).
+type subdomainPattern struct{ prefix, suffix string }
+
+func (p subdomainPattern) matches(origin string) bool {
+	host, ok := strings.CutPrefix(origin, p.prefix)
+	if !ok || len(host) <= len(p.suffix) || !strings.HasSuffix(host, p.suffix) {
+		return false
+	}
+	sub := host[:len(host)-len(p.suffix)]
+	return !strings.ContainsAny(sub, "/@:")
+}
+
+var corsHandlers atomic.Pointer[map[string]gin.HandlerFunc] // port -> handler; "" = default
+
+func init() {
+	if err := SetCORSPolicies(CORSPolicy{}, nil); err != nil {
+		panic(err)
+	}
+}
+
+// SetCORSPolicies swaps the policies of every CORS middleware: ports[name]
+// for that port, def for the others. Any invalid policy leaves all of them
+// as they were.
+func SetCORSPolicies(def CORSPolicy, ports map[string]CORSPolicy) error {
+	handlers := make(map[string]gin.HandlerFunc, len(ports)+1)
+	cfg, err := corsConfig(def)
+	if err != nil {
+		return err
+	}
+	handlers[""] = cors.New(cfg)
+	for name, p := range ports {
+		cfg, err := corsConfig(p)
+		if err != nil {
+			return fmt.Errorf("port %s: %w", name, err)
+		}
+		handlers[name] = cors.New(cfg)
+	}
+	corsHandlers.Store(&handlers)
+	return nil
+}
+
+// CORS applies the policy of the request's port (see SetCORSPolicies).
+func CORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handlers := *corsHandlers.Load()
+		h, ok := handlers[c.GetString("port")]
+		if !ok {
+			h = handlers[""]
+		}
+		h(c)
+	}
+}
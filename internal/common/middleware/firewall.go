@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+	"github.com/wiidz/gin_template/internal/common/firewall"
+	"github.com/wiidz/gin_template/internal/common/metrics"
+	"github.com/wiidz/gin_template/internal/common/response"
+)
+
+// Firewall rejects with 403 clients matching a deny rule, or missing the
+// allowlist of their port (see firewall.Check).
+func Firewall() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		port := c.GetString("port")
+		if d := firewall.Check(port, c.ClientIP()); !d.Allowed {
+			list := "allowlist"
+			if d.Rule != nil {
+				list = "denylist"
+			}
+			metrics.FirewallRejections.WithLabelValues(port, list).Inc()
+			response.Fail(c, apperr.Forbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Autoban reports every finished request to firewall.Observe, which bans
+// clients tripping the ban rules. 429s from the port-wide RateLimit are not
+// the client's fault and are not reported.
+func Autoban() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		status := c.Writer.Status()
+		if status == http.StatusTooManyRequests && c.GetString(rateLimitedByKey) == "global" {
+			return
+		}
+		firewall.Observe(c.Request.Context(), c.GetString("port"), c.FullPath(), c.ClientIP(), status)
+	}
+}
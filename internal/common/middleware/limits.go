@@ -0,0 +1,344 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+	"github.com/wiidz/gin_template/internal/common/response"
+)
+
+// RequestLimits caps the request body and handling time of a port.
+type RequestLimits struct {
+	MaxBodyBytes  int64         // 0 = unlimited
+	Timeout       time.Duration // 0 = no deadline
+	TimeoutStatus int           // 503 (default) or 504
+	Routes        []RouteLimit  // first match wins
+	// ReadTimeout and WriteTimeout are the port's http.Server timeouts,
+	// which routes allowed to run longer push out; 0 = none.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// LiftedTimeout bounds the connection of routes with a lifted timeout,
+	// e.g. streams; 0 means 1h.
+	LiftedTimeout time.Duration
+}
+
+const defaultLiftedTimeout = time.Hour
+
+// RouteLimit overrides RequestLimits for matching requests. Zero values keep
+// the port's limit, negative ones lift it.
+type RouteLimit struct {
+	Path         string // route template; a trailing * matches a prefix
+	Methods      []string
+	MaxBodyBytes int64
+	Timeout      time.Duration
+}
+
+func (r *RouteLimit) matches(c *gin.Context) bool {
+	if len(r.Methods) > 0 && !slices.ContainsFunc(r.Methods, func(m string) bool { return strings.EqualFold(m, c.Request.Method) }) {
+		return false
+	}
+	return matchRoute(r.Path, c.FullPath())
+}
+
+// route returns the first route override matching the request, or nil.
+func (l *RequestLimits) route(c *gin.Context) *RouteLimit {
+	for i := range l.Routes {
+		if l.Routes[i].matches(c) {
+			return &l.Routes[i]
+		}
+	}
+	return nil
+}
+
+// of returns the limits for the request: the port's, with the first
+// matching route's overrides applied.
+func (l *RequestLimits) of(c *gin.Context) (maxBody int64, timeout time.Duration) {
+	maxBody, timeout = l.MaxBodyBytes, l.Timeout
+	if r := l.route(c); r != nil {
+		if r.MaxBodyBytes != 0 {
+			maxBody = max(r.MaxBodyBytes, 0)
+		}
+		if r.Timeout != 0 {
+			timeout = max(r.Timeout, 0)
+		}
+	}
+	return maxBody, timeout
+}
+
+// extendDeadlines moves the connection deadlines of a route allowed to run
+// for d past the port's readTimeout / writeTimeout, keeping the port's
+// margin between the handler deadline and writeTimeout; d <= 0 (lifted)
+// stands for LiftedTimeout. Errors only mean the writer can't (e.g. in
+// tests), and the server's deadlines stay in place.
+func (l *RequestLimits) extendDeadlines(c *gin.Context, d time.Duration) {
+	if d <= 0 {
+		d = l.LiftedTimeout
+		if d <= 0 {
+			d = defaultLiftedTimeout
+		}
+	}
+	rc := http.NewResponseController(c.Writer)
+	now := time.Now()
+	if l.ReadTimeout > 0 && d > l.ReadTimeout {
+		_ = rc.SetReadDeadline(now.Add(d))
+	}
+	if w := d + max(l.WriteTimeout-l.Timeout, 0); l.WriteTimeout > 0 && w > l.WriteTimeout {
+		_ = rc.SetWriteDeadline(now.Add(w))
+	}
+}
+
+// matchRoute matches a gin route template against pattern, where a
+// trailing * matches a prefix. Unmatched requests (route "") only match an
+// empty pattern.
+func matchRoute(pattern, route string) bool {
+	if pattern == "" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return route != "" && strings.HasPrefix(route, prefix)
+	}
+	return route == pattern
+}
+
+// BodyLimit answers 413 to requests whose body is over the limit. Bodies of
+// unknown length (chunked) are read up front, at most limit bytes; others
+// are checked against Content-Length, which net/http enforces.
+func BodyLimit(l RequestLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := l.of(c)
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength < 0 {
+			body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				response.Fail(c, apperr.PayloadTooLarge)
+				return
+			}
+			if err != nil {
+				response.Fail(c, apperr.BadRequest.WithMessage("malformed request body").WithCause(err))
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Request.ContentLength = int64(len(body))
+		}
+		if c.Request.ContentLength > limit {
+			response.Fail(c, apperr.PayloadTooLarge)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Timeout gives the handler a deadline through c.Request.Context() and
+// answers 503 (or 504) when it passes, like http.TimeoutHandler: the
+// handler's headers are held back until it writes, so the timeout answer
+// can still go out. Whatever the handler writes afterwards is dropped. A
+// handler that already started its response (e.g. a stream) is not
+// answered for; the client then waits for it to return or for the port's
+// writeTimeout.
+//
+// The handler keeps running until it returns, so it should still give up
+// on a cancelled context.
+//
+// Routes whose timeout runs past the port's readTimeout / writeTimeout get
+// their connection deadlines moved out to match; a lifted timeout moves
+// them to LiftedTimeout.
+func Timeout(l RequestLimits) gin.HandlerFunc {
+	timedOut := apperr.Unavailable
+	if l.TimeoutStatus == http.StatusGatewayTimeout {
+		timedOut = apperr.Timeout
+	}
+	return func(c *gin.Context) {
+		_, d := l.of(c)
+		if r := l.route(c); r != nil && r.Timeout != 0 {
+			l.extendDeadlines(c, d)
+		}
+		if d <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		// the answer may be written from the timer's goroutine, so it gets
+		// a copy of c taken before the handler runs
+		answer := c.Copy()
+		w := &timeoutWriter{
+			ResponseWriter: c.Writer,
+			header:         c.Writer.Header().Clone(),
+			ctx:            ctx,
+			answer: func(rw gin.ResponseWriter) {
+				answer.Writer = rw
+				response.Fail(answer, timedOut.WithCause(ctx.Err()))
+			},
+		}
+		stop := context.AfterFunc(ctx, w.expire)
+		c.Writer = w
+		// also on panic, so Recovery can answer
+		defer func() {
+			stop()
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
+
+// timeoutWriter keeps the handler's status and headers to itself until it
+// writes, and once ctx's deadline has passed drops every write and sends
+// the deadline answer instead, if the handler had not started its response.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	header http.Header // the handler's headers; only touched by the handler
+	ctx    context.Context
+	answer func(gin.ResponseWriter) // renders the deadline answer
+
+	mu        sync.Mutex
+	status    int
+	committed bool // handed over to ResponseWriter
+	timedOut  bool // the deadline passed; writes are dropped
+	done      bool // the handler returned
+}
+
+func (w *timeoutWriter) expire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.expiredLocked()
+}
+
+// expiredLocked reports whether the deadline has passed, answering the
+// first time. Writes check it too: the AfterFunc may not have run yet.
+func (w *timeoutWriter) expiredLocked() bool {
+	if w.timedOut {
+		return true
+	}
+	if w.done || w.ctx.Err() != context.DeadlineExceeded {
+		return false
+	}
+	w.timedOut = true
+	if w.committed {
+		return true
+	}
+	// with a Content-Length the client can read the answer to the end while
+	// the handler still runs
+	aw := &answerWriter{ResponseWriter: w.ResponseWriter}
+	w.answer(aw)
+	w.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(aw.body.Len()))
+	_, _ = w.ResponseWriter.Write(aw.body.Bytes())
+	w.ResponseWriter.Flush()
+	return true
+}
+
+// finish hands over a response the handler did not write, e.g. a bare
+// c.Status(204), for gin to send.
+func (w *timeoutWriter) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.expiredLocked() {
+		w.commitLocked()
+	}
+	w.done = true
+}
+
+func (w *timeoutWriter) commitLocked() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	h := w.ResponseWriter.Header()
+	clear(h)
+	for k, v := range w.header {
+		h[k] = v
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *timeoutWriter) Header() http.Header { return w.header }
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.committed && !w.timedOut && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.committed && !w.expiredLocked() {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.expiredLocked() {
+		w.commitLocked()
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expiredLocked() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.commitLocked()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expiredLocked() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.commitLocked()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.expiredLocked() {
+		w.commitLocked()
+		w.ResponseWriter.Flush()
+	}
+}
+
+// Hijack is refused: a hijacked connection would outlive the deadline.
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+// answerWriter holds back the body of the deadline answer.
+type answerWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *answerWriter) Write(b []byte) (int, error)       { return w.body.Write(b) }
+func (w *answerWriter) WriteString(s string) (int, error) { return w.body.WriteString(s) }
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const deadline = 50 * time.Millisecond
+	tests := []struct {
+		name       string
+		status     int
+		handler    gin.HandlerFunc
+		want       int
+		wantBody   string
+		wantHeader string // X-Handler as sent
+	}{
+		{
+			name: "in time",
+			handler: func(c *gin.Context) {
+				c.Header("X-Handler", "1")
+				c.String(http.StatusOK, "done")
+			},
+			want: http.StatusOK, wantBody: "done", wantHeader: "1",
+		},
+		{
+			name: "bare status in time",
+			handler: func(c *gin.Context) {
+				c.Header("X-Handler", "1")
+				c.Status(http.StatusNoContent)
+			},
+			want: http.StatusNoContent, wantHeader: "1",
+		},
+		{
+			name: "handler gives up on the context",
+			handler: func(c *gin.Context) {
+				<-c.Request.Context().Done()
+				c.String(http.StatusInternalServerError, "cancelled")
+			},
+			want: http.StatusServiceUnavailable,
+		},
+		{
+			name:   "504",
+			status: http.StatusGatewayTimeout,
+			handler: func(c *gin.Context) {
+				<-c.Request.Context().Done()
+			},
+			want: http.StatusGatewayTimeout,
+		},
+		{
+			name: "handler ignores the context",
+			handler: func(c *gin.Context) {
+				c.Header("X-Handler", "1")
+				c.Status(http.StatusNoContent)
+				time.Sleep(3 * deadline)
+				c.String(http.StatusOK, "late")
+			},
+			want: http.StatusServiceUnavailable, // without the handler's headers
+		},
+		{
+			name: "body sent in time",
+			handler: func(c *gin.Context) {
+				c.String(http.StatusOK, "partial")
+				c.Writer.Flush()
+				time.Sleep(2 * deadline)
+				_, _ = c.Writer.WriteString(" and late")
+			},
+			want: http.StatusOK, wantBody: "partial",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			e.Use(Timeout(RequestLimits{Timeout: deadline, TimeoutStatus: tt.status}))
+			e.GET("/", tt.handler)
+
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (body %q)", w.Code, tt.want, w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Fatalf("body = %q, want %q", w.Body, tt.wantBody)
+			}
+			if got := w.Header().Get("X-Handler"); got != tt.wantHeader {
+				t.Fatalf("X-Handler = %q, want %q", got, tt.wantHeader)
+			}
+		})
+	}
+}
+
+// TestTimeoutAnswersAtDeadline runs a real server: the client gets the
+// whole timeout answer while the handler is still busy.
+func TestTimeoutAnswersAtDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	release := make(chan struct{})
+	e := gin.New()
+	e.Use(Timeout(RequestLimits{Timeout: 50 * time.Millisecond}))
+	e.GET("/", func(c *gin.Context) {
+		<-release // ignores its context
+		c.String(http.StatusOK, "late")
+	})
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	client := srv.Client()
+	client.Timeout = time.Second
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("read answer: %v", err)
+	}
+	if res.StatusCode != http.StatusServiceUnavailable || len(body) == 0 {
+		t.Fatalf("answer = %d %q, want a 503 body", res.StatusCode, body)
+	}
+}
+
+func TestTimeoutRouteOverrides(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := RequestLimits{
+		Timeout: 50 * time.Millisecond,
+		Routes: []RouteLimit{
+			{Path: "/slow", Timeout: time.Second},
+			{Path: "/stream", Timeout: -1},
+			{Path: "/fast", Methods: []string{"POST"}, Timeout: 10 * time.Millisecond},
+		},
+	}
+	e := gin.New()
+	e.Use(Timeout(l))
+	sleep := func(c *gin.Context) {
+		time.Sleep(100 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	}
+	e.GET("/slow", sleep)
+	e.GET("/stream", sleep)
+	e.GET("/fast", sleep)
+	e.GET("/other", sleep)
+
+	for path, want := range map[string]int{
+		"/slow":   http.StatusOK,
+		"/stream": http.StatusOK,
+		"/fast":   http.StatusServiceUnavailable, // POST only, port limit applies
+		"/other":  http.StatusServiceUnavailable,
+	} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+// TestTimeoutExtendsDeadlines runs a real server: a route allowed to run
+// past the port's writeTimeout must still get its response out, up to
+// LiftedTimeout for lifted ones.
+func TestTimeoutExtendsDeadlines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const serverTimeout = 100 * time.Millisecond
+	l := RequestLimits{
+		ReadTimeout:   serverTimeout,
+		WriteTimeout:  serverTimeout,
+		LiftedTimeout: 500 * time.Millisecond,
+		Routes: []RouteLimit{
+			{Path: "/upload", Timeout: time.Second},
+			{Path: "/stream", Timeout: -1},
+		},
+	}
+	e := gin.New()
+	e.Use(Timeout(l))
+	sleep := func(c *gin.Context) {
+		d, _ := time.ParseDuration(c.Query("sleep"))
+		time.Sleep(d)
+		c.String(http.StatusOK, "done")
+	}
+	e.GET("/upload", sleep)
+	e.GET("/stream", sleep)
+	e.GET("/other", sleep)
+
+	srv := httptest.NewUnstartedServer(e)
+	srv.Config.ReadTimeout = serverTimeout
+	srv.Config.WriteTimeout = serverTimeout
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		path string
+		want bool // response received
+	}{
+		{"/upload?sleep=300ms", true},
+		{"/stream?sleep=300ms", true},
+		{"/stream?sleep=900ms", false}, // past LiftedTimeout plus the write margin
+		{"/other?sleep=300ms", false},
+	}
+	for _, tt := range tests {
+		res, err := srv.Client().Get(srv.URL + tt.path)
+		var body []byte
+		if err == nil {
+			body, err = io.ReadAll(res.Body)
+			res.Body.Close()
+		}
+		if got := err == nil && string(body) == "done"; got != tt.want {
+			t.Errorf("GET %s = %q, %v; want response %v", tt.path, body, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+	"github.com/wiidz/gin_template/internal/common/metrics"
+	"github.com/wiidz/gin_template/internal/common/response"
+)
+
+// Metrics records request count, latency and in-flight requests. It must run
+// after the port is set on the context. Unmatched paths share one route
+// label so scanners cannot blow up label cardinality.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		port := c.GetString("port")
+		inFlight := metrics.HTTPInFlight.WithLabelValues(port)
+		inFlight.Inc()
+		start := time.Now()
+
+		c.Next()
+
+		inFlight.Dec()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		metrics.HTTPRequests.WithLabelValues(port, method, route, metrics.StatusClass(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(port, method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsAccessPolicy lets scrapers reach /metrics: clients inside Allow,
+// or presenting "Authorization: Bearer <BearerToken>". The zero value lets
+// nobody in.
+type MetricsAccessPolicy struct {
+	Allow       []netip.Prefix
+	BearerToken string
+}
+
+var metricsAccess atomic.Pointer[MetricsAccessPolicy]
+
+func init() { SetMetricsAccess(MetricsAccessPolicy{}) }
+
+// SetMetricsAccess swaps the policy of every MetricsAccess middleware.
+func SetMetricsAccess(p MetricsAccessPolicy) { metricsAccess.Store(&p) }
+
+// MetricsAccess answers 403 to requests the policy (see SetMetricsAccess)
+// does not let in. The client IP honours the port's trusted proxies.
+func MetricsAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := metricsAccess.Load()
+		if p.BearerToken != "" {
+			token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if ok && subtle.ConstantTimeCompare([]byte(token), []byte(p.BearerToken)) == 1 {
+				c.Next()
+				return
+			}
+		}
+		if ip, err := netip.ParseAddr(c.ClientIP()); err == nil {
+			ip = ip.Unmap()
+			for _, prefix := range p.Allow {
+				if prefix.Contains(ip) {
+					c.Next()
+					return
+				}
+			}
+		}
+		response.Fail(c, apperr.Forbidden)
+	}
+}
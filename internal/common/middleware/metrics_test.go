@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetricsAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy := MetricsAccessPolicy{
+		Allow:       []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")},
+		BearerToken: "s3cret",
+	}
+	tests := []struct {
+		name   string
+		policy MetricsAccessPolicy
+		remote string
+		auth   string
+		want   int
+	}{
+		{"allowed IPv4", policy, "10.1.2.3:5000", "", http.StatusOK},
+		{"allowed IPv6", policy, "[::1]:5000", "", http.StatusOK},
+		{"IPv4-mapped IPv6", policy, "[::ffff:10.1.2.3]:5000", "", http.StatusOK},
+		{"outside allow", policy, "192.0.2.1:5000", "", http.StatusForbidden},
+		{"bearer token", policy, "192.0.2.1:5000", "Bearer s3cret", http.StatusOK},
+		{"wrong token", policy, "192.0.2.1:5000", "Bearer nope", http.StatusForbidden},
+		{"token without scheme", policy, "192.0.2.1:5000", "s3cret", http.StatusForbidden},
+		{"zero policy", MetricsAccessPolicy{}, "127.0.0.1:5000", "Bearer ", http.StatusForbidden},
+	}
+	t.Cleanup(func() { SetMetricsAccess(MetricsAccessPolicy{}) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetMetricsAccess(tt.policy)
+			e := gin.New()
+			_ = e.SetTrustedProxies(nil)
+			e.GET("/metrics", MetricsAccess(), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set("X-Forwarded-For", "10.0.0.1") // untrusted, must be ignored
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -3,12 +3,13 @@ package middleware
 import (
 	"time"
 
-	"github.com/gin-contrib/cors"
+	"github.com/click33/sa-token-go/stputil"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/tracing"
 )
 
 func RequestID() gin.HandlerFunc {
@@ -23,47 +24,68 @@ func RequestID() gin.HandlerFunc {
 	}
 }
 
+// RequestLogger stores a logger carrying the request ID, port, client IP,
+// login ID and trace ID in the request context; read it back with
+// logger.FromContext(ctx). Place it after RequestID and Tracing.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fields := []zap.Field{
+			zap.String("rid", c.GetString("request_id")),
+			zap.String("ip", c.ClientIP()),
+		}
+		if port := c.GetString("port"); port != "" {
+			fields = append(fields, zap.String("port", port))
+		}
+		if id := LoginID(c); id != "" {
+			fields = append(fields, zap.String("login_id", id))
+		}
+		ctx := c.Request.Context()
+		fields = append(fields, tracing.LogFields(ctx)...)
+		c.Request = c.Request.WithContext(logger.NewContext(ctx, logger.With(fields...)))
+		c.Next()
+	}
+}
+
+// LoginID resolves the caller from the access token, best effort: a
+// missing, expired or unknown token yields "".
+func LoginID(c *gin.Context) (id string) {
+	token := c.GetHeader("Authorization")
+	if token == "" {
+		token = c.GetHeader("satoken")
+	}
+	if token == "" {
+		return ""
+	}
+	defer func() {
+		// stputil panics until identityMng has installed its manager
+		if recover() != nil {
+			id = ""
+		}
+	}()
+	id, _ = stputil.GetLoginID(token)
+	return id
+}
+
 func AccessLog() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		c.Next()
 		latency := time.Since(start)
-		ridVal, _ := c.Get("request_id")
-		rid, _ := ridVal.(string)
-		portVal, _ := c.Get("port")
-		port, _ := portVal.(string)
 		path := c.FullPath()
 		if path == "" {
 			path = c.Request.URL.Path
 		}
-		fields := []zap.Field{
-			zap.String("rid", rid),
+		// rid, ip, port, login_id and trace_id come from RequestLogger
+		logger.FromContext(c.Request.Context()).Named("access").Info("access",
 			zap.String("method", c.Request.Method),
 			zap.String("path", path),
 			zap.Int("status", c.Writer.Status()),
 			zap.Duration("latency", latency),
-			zap.String("ip", c.ClientIP()),
 			zap.Int("size", c.Writer.Size()),
-		}
-		if port != "" {
-			fields = append(fields, zap.String("port", port))
-		}
-		logger.L.Info("access", fields...)
+		)
 	}
 }
 
 func Recovery() gin.HandlerFunc {
 	return gin.Recovery()
 }
-
-func CORS() gin.HandlerFunc {
-	cfg := cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Request-ID"},
-		ExposeHeaders:    []string{"X-Request-ID"},
-		AllowCredentials: false,
-		MaxAge:           12 * time.Hour,
-	}
-	return cors.New(cfg)
-}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wiidz/gin_template/internal/common/logger"
+)
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.InfoLevel)
+	prev := logger.L
+	logger.L = zap.New(core)
+	t.Cleanup(func() { logger.L = prev })
+
+	// a service only sees the context
+	service := func(ctx context.Context) { logger.FromContext(ctx).Info("service_call") }
+	e := gin.New()
+	e.Use(func(c *gin.Context) { c.Set("port", "client") }, RequestID(), RequestLogger(), AccessLog())
+	e.GET("/users/:id", func(c *gin.Context) {
+		service(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.RemoteAddr = "192.0.2.7:5000"
+	req.Header.Set("X-Request-ID", "rid-1")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := map[string]any{"rid": "rid-1", "ip": "192.0.2.7", "port": "client"}
+	for _, msg := range []string{"service_call", "access"} {
+		entries := logs.FilterMessage(msg).All()
+		if len(entries) != 1 {
+			t.Fatalf("%d %s entries", len(entries), msg)
+		}
+		got := entries[0].ContextMap()
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s: %s = %v, want %v", msg, k, got[k], v)
+			}
+		}
+		// no span without Tracing, no caller without a token
+		for _, k := range []string{"trace_id", "login_id"} {
+			if _, ok := got[k]; ok {
+				t.Errorf("%s: unexpected %s", msg, k)
+			}
+		}
+	}
+	access := logs.FilterMessage("access").All()[0]
+	if got := access.ContextMap(); access.LoggerName != "access" || got["path"] != "/users/:id" || got["status"] != int64(http.StatusNoContent) {
+		t.Fatalf("access entry = %s %v", access.LoggerName, got)
+	}
+}
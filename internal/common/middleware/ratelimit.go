@@ -1,64 +1,154 @@
 package middleware
 
 import (
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
+	"go.uber.org/zap"
 
+	"github.com/wiidz/gin_template/internal/common/apperr"
+	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/metrics"
+	"github.com/wiidz/gin_template/internal/common/ratelimit"
 	"github.com/wiidz/gin_template/internal/common/response"
 )
 
-// RateLimit applies a global token-bucket limiter per process.
+var (
+	limitMu       sync.Mutex
+	globalLimits  []*atomic.Pointer[ratelimit.Limit] // one per RateLimit middleware
+	ipLimits      []*atomic.Pointer[ratelimit.Limit] // one per RateLimitIP middleware
+	limiterStore  atomic.Pointer[storeHolder]
+	defaultMemory = ratelimit.NewMemory(ratelimit.DefaultMaxKeys)
+	activity      = ratelimit.NewTracker(10_000)
+)
+
+// rateLimitedByKey names the limiter that rejected the request.
+const rateLimitedByKey = "rate_limited_by"
+
+type storeHolder struct{ ratelimit.Store }
+
+// SetLimiterStore replaces the store behind every rate limiter, e.g. a
+// ratelimit.Fallback over Redis to share limits between replicas. Until it
+// is called limits are kept in process.
+func SetLimiterStore(s ratelimit.Store) {
+	limiterStore.Store(&storeHolder{s})
+}
+
+func currentStore() ratelimit.Store {
+	if h := limiterStore.Load(); h != nil {
+		return h.Store
+	}
+	return defaultMemory
+}
+
+func newLimit(rps float64, burst int, list *[]*atomic.Pointer[ratelimit.Limit]) *atomic.Pointer[ratelimit.Limit] {
+	lim := new(atomic.Pointer[ratelimit.Limit])
+	lim.Store(&ratelimit.Limit{Rate: rps, Burst: burst})
+	limitMu.Lock()
+	*list = append(*list, lim)
+	limitMu.Unlock()
+	return lim
+}
+
+// allow counts the request in the bucket <limiter>:<port>[:<key>] and sets
+// the RateLimit-* headers. A store error lets the request through: limiting
+// is not worth an outage.
+func allow(c *gin.Context, limiter, key string, limit ratelimit.Limit) bool {
+	port := c.GetString("port")
+	k := limiter + ":" + port
+	if key != "" {
+		k += ":" + key
+	}
+	res, err := currentStore().Allow(c.Request.Context(), k, limit)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Named("ratelimit").Warn("rate_limit_store_error", zap.Error(err))
+		return true
+	}
+	activity.Record(limiter, k, res)
+	setRateLimitHeaders(c, res)
+	if !res.Allowed {
+		c.Set(rateLimitedByKey, limiter)
+		metrics.RateLimitRejections.WithLabelValues(port, limiter).Inc()
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		response.Fail(c, apperr.TooManyRequests)
+		return false
+	}
+	return true
+}
+
+// setRateLimitHeaders writes the RateLimit-Limit, -Remaining and -Reset
+// headers (IETF draft, Reset in seconds). With several limiters on a route
+// the one with the fewest requests remaining wins.
+func setRateLimitHeaders(c *gin.Context, res ratelimit.Result) {
+	h := c.Writer.Header()
+	if prev := h.Get("RateLimit-Remaining"); prev != "" {
+		if n, err := strconv.Atoi(prev); err == nil && n <= res.Remaining {
+			return
+		}
+	}
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// RateLimitBuckets lists up to n recently used buckets of this process,
+// most recent first.
+func RateLimitBuckets(n int) []ratelimit.Bucket { return activity.Buckets(n) }
+
+// RateLimitOffenders lists up to n buckets of this process by rejections.
+func RateLimitOffenders(n int) []ratelimit.Bucket { return activity.TopOffenders(n) }
+
+// RateLimit applies one token bucket to all requests of the port; with a
+// shared store it is shared by every replica.
 // rps: tokens per second; burst: maximum burst size.
 func RateLimit(rps float64, burst int) gin.HandlerFunc {
-	lim := rate.NewLimiter(rate.Limit(rps), burst)
+	lim := newLimit(rps, burst, &globalLimits)
 	return func(c *gin.Context) {
-		if !lim.Allow() {
-			response.Error(c, 429, "too many requests")
+		if !allow(c, "global", "", *lim.Load()) {
 			return
 		}
 		c.Next()
 	}
 }
 
-// RateLimitIP applies per-IP token-bucket limiting.
+// RateLimitIP applies a token bucket per client IP to requests no
+// RateLimitPolicies policy matched.
 func RateLimitIP(rps float64, burst int) gin.HandlerFunc {
-	var limiters sync.Map // ip -> *rate.Limiter
-	newLimiter := func() *rate.Limiter { return rate.NewLimiter(rate.Limit(rps), burst) }
+	lim := newLimit(rps, burst, &ipLimits)
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		v, ok := limiters.Load(ip)
-		if !ok {
-			lim := newLimiter()
-			v, _ = limiters.LoadOrStore(ip, lim)
+		if _, ok := c.Get(ratePolicyKey); ok {
+			c.Next()
+			return
 		}
-		lim := v.(*rate.Limiter)
-		if !lim.Allow() {
-			response.Error(c, 429, "too many requests")
+		if !allow(c, "ip", c.ClientIP(), *lim.Load()) {
 			return
 		}
 		c.Next()
 	}
 }
 
-// In-memory IP denylist
-var denyIPs sync.Map // ip -> struct{}
+// SetRateLimit retunes every RateLimit middleware without resetting buckets.
+func SetRateLimit(rps float64, burst int) {
+	setLimits(globalLimits, rps, burst)
+}
 
-func DenyIP(ip string)  { denyIPs.Store(ip, struct{}{}) }
-func AllowIP(ip string) { denyIPs.Delete(ip) }
-func IsDenied(ip string) bool {
-	_, ok := denyIPs.Load(ip)
-	return ok
+// SetRateLimitIP retunes every RateLimitIP middleware without resetting
+// buckets.
+func SetRateLimitIP(rps float64, burst int) {
+	setLimits(ipLimits, rps, burst)
 }
 
-// IPDenylist blocks requests from denied IPs with 403.
-func IPDenylist() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		if IsDenied(c.ClientIP()) {
-			response.Error(c, 403, "forbidden")
-			return
-		}
-		c.Next()
+func setLimits(list []*atomic.Pointer[ratelimit.Limit], rps float64, burst int) {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+	for _, lim := range list {
+		lim.Store(&ratelimit.Limit{Rate: rps, Burst: burst})
 	}
 }
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/click33/sa-token-go/stputil"
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/common/ratelimit"
+)
+
+// Keys a RateLimitPolicy can count requests by.
+const (
+	KeyIP      = "ip"       // client IP
+	KeyLoginID = "login_id" // logged-in caller, IP for anonymous requests
+	KeyAPIKey  = "api_key"  // API key header listed in APIKeyHashes, else IP
+	KeyHeader  = "header"   // value of Header listed in Values, else IP
+	KeyGlobal  = "global"   // one bucket for every matching request
+)
+
+// DefaultAPIKeyHeader is read for KeyAPIKey when Header is empty.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// RateLimitPolicy limits the requests it matches, one bucket per key.
+// Empty match fields match everything.
+type RateLimitPolicy struct {
+	Name    string   `json:"name"`
+	Ports   []string `json:"ports,omitempty"`
+	Path    string   `json:"path,omitempty"` // route template; a trailing * matches a prefix
+	Methods []string `json:"methods,omitempty"`
+	Roles   []string `json:"roles,omitempty"` // callers with any of these roles
+	Key     string   `json:"key"`
+	Header  string   `json:"header,omitempty"`
+	RPS     float64  `json:"rps"`
+	Burst   int      `json:"burst"`
+
+	// Only known keys get their own bucket; clients could otherwise send a
+	// fresh one per request. APIKeyHashes are hex SHA-256 digests of the
+	// API keys, Values the accepted values of Header.
+	APIKeyHashes []string `json:"-"`
+	Values       []string `json:"-"`
+}
+
+func (p *RateLimitPolicy) matches(c *gin.Context, caller *callerOf) bool {
+	if len(p.Ports) > 0 && !slices.Contains(p.Ports, c.GetString("port")) {
+		return false
+	}
+	if len(p.Methods) > 0 && !slices.ContainsFunc(p.Methods, func(m string) bool { return strings.EqualFold(m, c.Request.Method) }) {
+		return false
+	}
+	if !matchRoute(p.Path, c.FullPath()) {
+		return false
+	}
+	return len(p.Roles) == 0 || caller.hasRole(p.Roles)
+}
+
+// key names the caller's bucket, e.g. login_id:42.
+func (p *RateLimitPolicy) key(c *gin.Context, caller *callerOf) string {
+	switch p.Key {
+	case KeyGlobal:
+		return ""
+	case KeyLoginID:
+		if id := caller.id(); id != "" {
+			return KeyLoginID + ":" + id
+		}
+	case KeyAPIKey, KeyHeader:
+		header := p.Header
+		if header == "" {
+			header = DefaultAPIKeyHeader
+		}
+		v := c.GetHeader(header)
+		if v == "" {
+			break
+		}
+		if p.Key == KeyHeader {
+			if slices.Contains(p.Values, v) {
+				return KeyHeader + ":" + v
+			}
+			break
+		}
+		sum := sha256.Sum256([]byte(v))
+		if hash := hex.EncodeToString(sum[:]); slices.Contains(p.APIKeyHashes, hash) {
+			// keep secrets out of Redis and the console listing
+			return KeyAPIKey + ":" + hash[:16]
+		}
+	}
+	return KeyIP + ":" + c.ClientIP()
+}
+
+// callerOf resolves the login ID once per request, and only if a policy
+// needs it.
+type callerOf struct {
+	c        *gin.Context
+	resolved bool
+	loginID  string
+}
+
+func (w *callerOf) id() string {
+	if !w.resolved {
+		w.loginID, w.resolved = LoginID(w.c), true
+	}
+	return w.loginID
+}
+
+func (w *callerOf) hasRole(roles []string) (ok bool) {
+	id := w.id()
+	if id == "" {
+		return false
+	}
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return stputil.HasRolesOr(id, roles)
+}
+
+var ratePolicies atomic.Pointer[[]RateLimitPolicy]
+
+// SetRateLimitPolicies replaces the policies applied by RateLimitPolicies.
+// Buckets are named after the policy, so retuning one keeps its buckets.
+func SetRateLimitPolicies(policies []RateLimitPolicy) {
+	ratePolicies.Store(&policies)
+}
+
+// CurrentRateLimitPolicies returns the policies in effect.
+func CurrentRateLimitPolicies() []RateLimitPolicy {
+	if p := ratePolicies.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// ratePolicyKey holds the name of the policy that took over from
+// RateLimitIP.
+const ratePolicyKey = "rate_limit_policy"
+
+// RateLimitPolicies applies the first policy matching the request, on top
+// of RateLimit and instead of RateLimitIP, so a policy may loosen the IP
+// limit as well as tighten it. It must run before RateLimitIP. Put specific
+// policies (e.g. admins) before general ones.
+func RateLimitPolicies() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller := &callerOf{c: c}
+		for _, p := range CurrentRateLimitPolicies() {
+			if !p.matches(c, caller) {
+				continue
+			}
+			c.Set(ratePolicyKey, p.Name)
+			if !allow(c, p.Name, p.key(c, caller), ratelimit.Limit{Rate: p.RPS, Burst: p.Burst}) {
+				return
+			}
+			break
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitPolicyKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sum := sha256.Sum256([]byte("partner-key"))
+	hash := hex.EncodeToString(sum[:])
+	apiKey := RateLimitPolicy{Key: KeyAPIKey, APIKeyHashes: []string{hash}}
+	header := RateLimitPolicy{Key: KeyHeader, Header: "X-Tenant", Values: []string{"acme"}}
+
+	tests := []struct {
+		name    string
+		policy  RateLimitPolicy
+		headers map[string]string
+		want    string
+	}{
+		{"known api key", apiKey, map[string]string{"X-API-Key": "partner-key"}, "api_key:" + hash[:16]},
+		{"unknown api key", apiKey, map[string]string{"X-API-Key": "made-up"}, "ip:192.0.2.1"},
+		{"missing api key", apiKey, nil, "ip:192.0.2.1"},
+		{"api key in a custom header", RateLimitPolicy{Key: KeyAPIKey, Header: "X-Partner", APIKeyHashes: []string{hash}}, map[string]string{"X-Partner": "partner-key"}, "api_key:" + hash[:16]},
+		{"known header value", header, map[string]string{"X-Tenant": "acme"}, "header:acme"},
+		{"unknown header value", header, map[string]string{"X-Tenant": "evil"}, "ip:192.0.2.1"},
+		{"ip", RateLimitPolicy{Key: KeyIP}, nil, "ip:192.0.2.1"},
+		{"global", RateLimitPolicy{Key: KeyGlobal}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:5000"
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = req
+			if got := tt.policy.key(c, &callerOf{c: c}); got != tt.want {
+				t.Fatalf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitPolicyReplacesIPLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetRateLimitPolicies([]RateLimitPolicy{
+		{Name: "bulk", Path: "/bulk", Key: KeyIP, RPS: 100, Burst: 10},
+		{Name: "strict", Path: "/strict", Key: KeyIP, RPS: 0.001, Burst: 1},
+	})
+	t.Cleanup(func() { SetRateLimitPolicies(nil) })
+
+	e := gin.New()
+	e.Use(func(c *gin.Context) { c.Set("port", t.Name()); c.Next() }, RateLimitPolicies(), RateLimitIP(0.001, 2))
+	for _, path := range []string{"/bulk", "/strict", "/other"} {
+		e.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	hit := func(path string, n int) (allowed int) {
+		for range n {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.RemoteAddr = "192.0.2.1:5000"
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code == http.StatusOK {
+				allowed++
+			}
+		}
+		return allowed
+	}
+
+	if got := hit("/bulk", 10); got != 10 {
+		t.Errorf("bulk allowed %d of 10, want all: the policy loosens the IP limit of 2", got)
+	}
+	if got := hit("/strict", 3); got != 1 {
+		t.Errorf("strict allowed %d of 3, want 1", got)
+	}
+	if got := hit("/other", 3); got != 2 {
+		t.Errorf("other allowed %d of 3, want the IP limit of 2 untouched by policy traffic", got)
+	}
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityPolicy is the security header setup of a port. Empty header
+// values take the defaults below and HeaderOff omits the header.
+type SecurityPolicy struct {
+	// HSTS is sent on HTTPS requests only; zero HSTSMaxAge omits it.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	CSP                string // {nonce} is replaced by the request's CSPNonce
+	FrameOptions       string
+	ReferrerPolicy     string
+	PermissionsPolicy  string
+	COOP               string
+	COEP               string
+	ContentTypeOptions string
+
+	// RedirectHTTPS answers plain HTTP requests with a 308 to HTTPS on the
+	// request's host, at HTTPSPort when set (e.g. "8443"), else at 443.
+	RedirectHTTPS bool
+	HTTPSPort     string
+	// TrustedProxies may tell, via X-Forwarded-Proto, that a request reached
+	// them over HTTPS. Use the port's gin trusted proxies.
+	TrustedProxies []netip.Prefix
+}
+
+// HeaderOff as a SecurityPolicy header value omits the header.
+const HeaderOff = "off"
+
+const (
+	defaultCSP               = "default-src 'none'; frame-ancestors 'none'; base-uri 'none'"
+	defaultFrameOptions      = "DENY"
+	defaultReferrerPolicy    = "strict-origin-when-cross-origin"
+	defaultPermissionsPolicy = "camera=(), microphone=(), geolocation=(), payment=()"
+	defaultCOOP              = "same-origin"
+	defaultContentTypeOpts   = "nosniff"
+)
+
+const cspNonceKey = "csp_nonce"
+
+// CSPNonce returns the nonce of the request's Content-Security-Policy, for
+// <script nonce="..."> in rendered pages; empty when the port's CSP has no
+// {nonce}.
+func CSPNonce(c *gin.Context) string {
+	return c.GetString(cspNonceKey)
+}
+
+// SecurityHeaders sets the port's security headers before the handler runs,
+// so errors and panics answered further down carry them too.
+func SecurityHeaders(p SecurityPolicy) gin.HandlerFunc {
+	static := map[string]string{
+		"X-Frame-Options":              or(p.FrameOptions, defaultFrameOptions),
+		"Referrer-Policy":              or(p.ReferrerPolicy, defaultReferrerPolicy),
+		"Permissions-Policy":           or(p.PermissionsPolicy, defaultPermissionsPolicy),
+		"Cross-Origin-Opener-Policy":   or(p.COOP, defaultCOOP),
+		"Cross-Origin-Embedder-Policy": or(p.COEP, HeaderOff), // breaks cross-origin embeds, opt in
+		"X-Content-Type-Options":       or(p.ContentTypeOptions, defaultContentTypeOpts),
+	}
+	for k, v := range static {
+		if v == HeaderOff {
+			delete(static, k)
+		}
+	}
+	csp := or(p.CSP, defaultCSP)
+	nonced := strings.Contains(csp, "{nonce}")
+	hsts := ""
+	if p.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(p.HSTSMaxAge/time.Second))
+		if p.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if p.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		for k, v := range static {
+			h.Set(k, v)
+		}
+		if csp != HeaderOff {
+			v := csp
+			if nonced {
+				nonce := newNonce()
+				c.Set(cspNonceKey, nonce)
+				v = strings.ReplaceAll(csp, "{nonce}", nonce)
+			}
+			h.Set("Content-Security-Policy", v)
+		}
+		if hsts != "" && isHTTPS(c, p.TrustedProxies) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+// HTTPSRedirect sends plain HTTP requests to HTTPS when p.RedirectHTTPS is
+// set; it does nothing otherwise.
+func HTTPSRedirect(p SecurityPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !p.RedirectHTTPS || isHTTPS(c, p.TrustedProxies) {
+			c.Next()
+			return
+		}
+		// the request's port is the plain HTTP one, never the HTTPS one
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]")
+		}
+		if p.HTTPSPort != "" && p.HTTPSPort != "443" {
+			host = net.JoinHostPort(host, p.HTTPSPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		c.Redirect(http.StatusPermanentRedirect, "https://"+host+c.Request.URL.RequestURI())
+		c.Abort()
+	}
+}
+
+// isHTTPS reports whether the client's request was made over TLS, either to
+// us or to a trusted proxy in front of us.
+func isHTTPS(c *gin.Context, trusted []netip.Prefix) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	proto := c.GetHeader("X-Forwarded-Proto")
+	if proto == "" {
+		return false
+	}
+	peer, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	peer = peer.Unmap()
+	for _, p := range trusted {
+		if p.Contains(peer) {
+			// the first hop's scheme when proxies append to the header
+			first, _, _ := strings.Cut(proto, ",")
+			return strings.EqualFold(strings.TrimSpace(first), "https")
+		}
+	}
+	return false
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func or(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var proxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+// securityRequest builds a GET from remote, over TLS when tls is set, with
+// an optional X-Forwarded-Proto.
+func securityRequest(target, remote string, overTLS bool, proto string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = remote + ":40000"
+	if !overTLS {
+		req.TLS = nil
+	} else if req.TLS == nil {
+		req.TLS = &tls.ConnectionState{}
+	}
+	if proto != "" {
+		req.Header.Set("X-Forwarded-Proto", proto)
+	}
+	return req
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		policy SecurityPolicy
+		want   map[string]string // "" = header absent
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{
+				"Content-Security-Policy":      defaultCSP,
+				"X-Frame-Options":              "DENY",
+				"Referrer-Policy":              defaultReferrerPolicy,
+				"Permissions-Policy":           defaultPermissionsPolicy,
+				"Cross-Origin-Opener-Policy":   "same-origin",
+				"Cross-Origin-Embedder-Policy": "",
+				"X-Content-Type-Options":       "nosniff",
+			},
+		},
+		{
+			name: "overrides and off",
+			policy: SecurityPolicy{
+				CSP:                HeaderOff,
+				FrameOptions:       "SAMEORIGIN",
+				ReferrerPolicy:     "no-referrer",
+				PermissionsPolicy:  HeaderOff,
+				COOP:               HeaderOff,
+				COEP:               "require-corp",
+				ContentTypeOptions: HeaderOff,
+			},
+			want: map[string]string{
+				"Content-Security-Policy":      "",
+				"X-Frame-Options":              "SAMEORIGIN",
+				"Referrer-Policy":              "no-referrer",
+				"Permissions-Policy":           "",
+				"Cross-Origin-Opener-Policy":   "",
+				"Cross-Origin-Embedder-Policy": "require-corp",
+				"X-Content-Type-Options":       "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			e.Use(SecurityHeaders(tt.policy))
+			e.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+			// on answers that never reach a handler too
+			for _, path := range []string{"/", "/missing"} {
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, securityRequest(path, "192.0.2.1", false, ""))
+				for k, v := range tt.want {
+					if got := w.Header().Get(k); got != v {
+						t.Errorf("%s: %s = %q, want %q", path, k, got, v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersCSPNonce(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(SecurityHeaders(SecurityPolicy{CSP: "script-src 'nonce-{nonce}'"}))
+	var nonces []string
+	e.GET("/", func(c *gin.Context) {
+		nonces = append(nonces, CSPNonce(c))
+		c.Status(http.StatusNoContent)
+	})
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, securityRequest("/", "192.0.2.1", false, ""))
+		nonce := nonces[len(nonces)-1]
+		if nonce == "" || w.Header().Get("Content-Security-Policy") != "script-src 'nonce-"+nonce+"'" {
+			t.Fatalf("CSP = %q with nonce %q", w.Header().Get("Content-Security-Policy"), nonce)
+		}
+	}
+	if nonces[0] == nonces[1] {
+		t.Fatal("nonce reused across requests")
+	}
+}
+
+func TestSecurityHeadersHSTS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(SecurityHeaders(SecurityPolicy{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		HSTSPreload:           true,
+		TrustedProxies:        proxies,
+	}))
+	e.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	const hsts = "max-age=31536000; includeSubDomains; preload"
+	tests := []struct {
+		name   string
+		remote string
+		tls    bool
+		proto  string
+		want   string
+	}{
+		{name: "TLS", remote: "192.0.2.1", tls: true, want: hsts},
+		{name: "plain HTTP", remote: "192.0.2.1"},
+		{name: "trusted proxy over HTTPS", remote: "10.0.0.2", proto: "https", want: hsts},
+		{name: "trusted proxy chain", remote: "10.0.0.2", proto: "https, http", want: hsts},
+		{name: "trusted proxy over HTTP", remote: "10.0.0.2", proto: "http"},
+		{name: "untrusted X-Forwarded-Proto", remote: "192.0.2.1", proto: "https"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, securityRequest("/", tt.remote, tt.tls, tt.proto))
+			if got := w.Header().Get("Strict-Transport-Security"); got != tt.want {
+				t.Fatalf("Strict-Transport-Security = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name      string
+		httpsPort string
+		target    string
+		remote    string
+		tls       bool
+		proto     string
+		want      string // Location; "" = passed through
+	}{
+		{name: "default port", target: "http://example.com/a?b=1", remote: "192.0.2.1", want: "https://example.com/a?b=1"},
+		{name: "plain port dropped", target: "http://example.com:8080/a", remote: "192.0.2.1", want: "https://example.com/a"},
+		{name: "IPv6 plain port dropped", target: "http://[2001:db8::1]:8080/", remote: "192.0.2.1", want: "https://[2001:db8::1]/"},
+		{name: "httpsPort", httpsPort: "8443", target: "http://example.com:8080/a", remote: "192.0.2.1", want: "https://example.com:8443/a"},
+		{name: "IPv6 httpsPort", httpsPort: "8443", target: "http://[2001:db8::1]:8080/", remote: "192.0.2.1", want: "https://[2001:db8::1]:8443/"},
+		{name: "httpsPort 443", httpsPort: "443", target: "http://example.com:8080/a", remote: "192.0.2.1", want: "https://example.com/a"},
+		{name: "TLS", target: "https://example.com/a", remote: "192.0.2.1", tls: true},
+		{name: "trusted X-Forwarded-Proto https", target: "http://example.com/a", remote: "10.0.0.2", proto: "https"},
+		{name: "trusted X-Forwarded-Proto http", target: "http://example.com/a", remote: "10.0.0.2", proto: "http", want: "https://example.com/a"},
+		{name: "untrusted X-Forwarded-Proto", target: "http://example.com/a", remote: "192.0.2.1", proto: "https", want: "https://example.com/a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			e.Use(HTTPSRedirect(SecurityPolicy{RedirectHTTPS: true, HTTPSPort: tt.httpsPort, TrustedProxies: proxies}))
+			e.GET("/*path", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, securityRequest(tt.target, tt.remote, tt.tls, tt.proto))
+			if tt.want == "" {
+				if w.Code != http.StatusNoContent {
+					t.Fatalf("status = %d, want the request passed through", w.Code)
+				}
+				return
+			}
+			if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != tt.want {
+				t.Fatalf("answer = %d %q, want 308 %q", w.Code, w.Header().Get("Location"), tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPSRedirectDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(HTTPSRedirect(SecurityPolicy{HTTPSPort: "8443"}))
+	e.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, securityRequest("http://example.com:8080/", "192.0.2.1", false, ""))
+	if w.Code != http.StatusNoContent || strings.Contains(w.Header().Get("Location"), "https") {
+		t.Fatalf("answer = %d %q, want no redirect", w.Code, w.Header().Get("Location"))
+	}
+}
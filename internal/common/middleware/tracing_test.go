@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/tracing"
+)
+
+// useMemoryTracing installs the memory exporter and an observed logger,
+// restoring the globals afterwards.
+func useMemoryTracing(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+	shutdown, err := tracing.Init(context.Background(), tracing.Options{ServiceName: "test", Exporter: tracing.ExporterMemory, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	core, logs := observer.New(zap.InfoLevel)
+	prev := logger.L
+	logger.L = zap.New(core)
+	t.Cleanup(func() {
+		_ = shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		logger.L = prev
+	})
+	return logs
+}
+
+func TestTracingServerSpan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := useMemoryTracing(t)
+
+	e := gin.New()
+	e.Use(Tracing(), RequestID(), RequestLogger())
+	e.GET("/users/:id", func(c *gin.Context) {
+		_, span := tracing.Tracer("test").Start(c.Request.Context(), "load")
+		span.End()
+		logger.FromContext(c.Request.Context()).Info("handled")
+		c.Status(http.StatusOK)
+	})
+	e.GET("/boom", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	const caller = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set("traceparent", "00-"+caller+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	spans := tracing.Memory().GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans, want the handler's and the server span", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if server.Name != "GET /users/:id" || server.SpanKind != trace.SpanKindServer {
+		t.Fatalf("server span = %q %v", server.Name, server.SpanKind)
+	}
+	if got := server.SpanContext.TraceID().String(); got != caller {
+		t.Fatalf("trace_id = %s, want the caller's %s", got, caller)
+	}
+	if child.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Fatal("handler span is not a child of the server span")
+	}
+	attrs := attribute.NewSet(server.Attributes...)
+	if v, _ := attrs.Value("http.route"); v.AsString() != "/users/:id" {
+		t.Errorf("http.route = %q", v.AsString())
+	}
+	if v, _ := attrs.Value("http.response.status_code"); v.AsInt64() != http.StatusOK {
+		t.Errorf("http.response.status_code = %d", v.AsInt64())
+	}
+	if got := w.Header().Get("traceparent"); got != "00-"+caller+"-"+server.SpanContext.SpanID().String()+"-01" {
+		t.Errorf("traceparent = %q", got)
+	}
+
+	entries := logs.FilterMessage("handled").All()
+	if len(entries) != 1 {
+		t.Fatalf("%d log entries", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["trace_id"] != caller || fields["span_id"] != server.SpanContext.SpanID().String() {
+		t.Fatalf("log fields = %v, want the server span's trace_id and span_id", fields)
+	}
+	if fields["rid"] != w.Header().Get("X-Request-ID") {
+		t.Fatalf("rid = %v, want %s", fields["rid"], w.Header().Get("X-Request-ID"))
+	}
+
+	tracing.Memory().Reset()
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/boom", nil))
+	spans = tracing.Memory().GetSpans()
+	if len(spans) != 1 || spans[0].Status.Code != codes.Error || spans[0].Parent.IsValid() {
+		t.Fatalf("spans = %+v, want one root span with an error status", spans)
+	}
+}
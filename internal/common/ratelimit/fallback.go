@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/metrics"
+)
+
+// DefaultRetryInterval is how long Fallback stays on the local store after
+// the primary fails before trying it again.
+const DefaultRetryInterval = 5 * time.Second
+
+// Fallback asks Primary and, when it errors, Local. After a failure it
+// skips Primary for RetryInterval so an unreachable Redis costs one timeout
+// per interval rather than one per request. While degraded every replica
+// limits on its own, so the effective limit is multiplied by the replica
+// count.
+type Fallback struct {
+	Primary       Store
+	Local         Store
+	RetryInterval time.Duration
+
+	downUntil atomic.Int64 // unix nanos; 0 while Primary is healthy
+}
+
+func NewFallback(primary, local Store) *Fallback {
+	return &Fallback{Primary: primary, Local: local, RetryInterval: DefaultRetryInterval}
+}
+
+func (f *Fallback) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	down := f.downUntil.Load()
+	if down == 0 || time.Now().UnixNano() >= down {
+		res, err := f.Primary.Allow(ctx, key, limit)
+		if err == nil {
+			if down != 0 && f.downUntil.CompareAndSwap(down, 0) {
+				metrics.RateLimitStoreDegraded.Set(0)
+				logger.FromContext(ctx).Named("ratelimit").Info("rate_limit_store_recovered")
+			}
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			// The request is gone; don't blame the store for it.
+			return f.Local.Allow(ctx, key, limit)
+		}
+		next := time.Now().Add(f.RetryInterval).UnixNano()
+		if f.downUntil.CompareAndSwap(down, next) {
+			metrics.RateLimitStoreErrors.Inc()
+			metrics.RateLimitStoreDegraded.Set(1)
+			if down == 0 {
+				logger.FromContext(ctx).Named("ratelimit").Warn("rate_limit_store_unavailable",
+					zap.Duration("retry_in", f.RetryInterval), zap.Error(err))
+			}
+		}
+	}
+	return f.Local.Allow(ctx, key, limit)
+}
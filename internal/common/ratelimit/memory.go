@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMaxKeys bounds a Memory store created with maxKeys <= 0.
+const DefaultMaxKeys = 100_000
+
+// Memory keeps arrival times in process. Keys whose bucket has refilled
+// carry no state and are dropped; beyond maxKeys the least recently used
+// key is evicted, which at worst hands that client a full bucket.
+type Memory struct {
+	mu      sync.Mutex
+	maxKeys int
+	keys    map[string]*list.Element
+	lru     *list.List // front = most recently used
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	key string
+	tat time.Time
+}
+
+func NewMemory(maxKeys int) *Memory {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+	return &Memory{maxKeys: maxKeys, keys: make(map[string]*list.Element), lru: list.New(), now: time.Now}
+}
+
+func (m *Memory) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+
+	var tat time.Time
+	el, ok := m.keys[key]
+	if ok {
+		tat = el.Value.(*memoryEntry).tat
+	}
+	next, res := gcra(limit, now, tat)
+	if ok {
+		el.Value.(*memoryEntry).tat = next
+		m.lru.MoveToFront(el)
+	} else if res.Allowed {
+		m.keys[key] = m.lru.PushFront(&memoryEntry{key: key, tat: next})
+	}
+	m.evict(now)
+	return res, nil
+}
+
+// Len reports the number of tracked keys.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.keys)
+}
+
+// evict drops refilled keys from the cold end, then the least recently
+// used ones while over capacity.
+func (m *Memory) evict(now time.Time) {
+	for el := m.lru.Back(); el != nil; el = m.lru.Back() {
+		e := el.Value.(*memoryEntry)
+		if len(m.keys) <= m.maxKeys && e.tat.After(now) {
+			return
+		}
+		m.lru.Remove(el)
+		delete(m.keys, e.key)
+	}
+}
@@ -0,0 +1,67 @@
+// Package ratelimit implements GCRA (a token bucket expressed as a
+// theoretical arrival time per key) over pluggable stores: Memory for a
+// single process, Redis to share limits between replicas, and Fallback to
+// keep limiting locally while Redis is unreachable.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit allows Rate requests per second on average, and bursts of up to
+// Burst requests.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// interval is the time one request "costs".
+func (l Limit) interval() time.Duration {
+	return time.Duration(float64(time.Second) / l.Rate)
+}
+
+// tolerance is how far ahead of now a key's arrival time may run.
+func (l Limit) tolerance() time.Duration {
+	return l.interval() * time.Duration(l.Burst)
+}
+
+// Result describes one decision, in the terms of the RateLimit-* headers.
+type Result struct {
+	Allowed    bool
+	Limit      int           // the burst size
+	Remaining  int           // requests allowed right now after this one
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed; 0 when Allowed
+}
+
+// Store decides whether one more request under key fits limit.
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// result builds the Result of a decision from the key's arrival time
+// relative to now (tat) after the request was (or would have been) counted.
+func result(limit Limit, allowed bool, tat, retryAfter time.Duration) Result {
+	r := Result{Allowed: allowed, Limit: limit.Burst, Reset: tat, RetryAfter: retryAfter}
+	if allowed {
+		r.Remaining = int(math.Floor(float64(limit.tolerance()-tat) / float64(limit.interval())))
+		r.Remaining = max(r.Remaining, 0)
+	}
+	return r
+}
+
+// gcra applies one request at now to a key whose theoretical arrival time
+// is tat (zero for an unseen key) and returns the new arrival time.
+func gcra(limit Limit, now, tat time.Time) (time.Time, Result) {
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(limit.interval())
+	allowAt := next.Add(-limit.tolerance())
+	if now.Before(allowAt) {
+		return tat, result(limit, false, tat.Sub(now), allowAt.Sub(now))
+	}
+	return next, result(limit, true, next.Sub(now), 0)
+}
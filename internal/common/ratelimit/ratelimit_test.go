@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// step is one request, after advancing the clock by after.
+type step struct {
+	after      time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+}
+
+func TestGCRA(t *testing.T) {
+	limit := Limit{Rate: 10, Burst: 5} // one request per 100ms, bursts of 5
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "burst then deny",
+			steps: []step{
+				{allowed: true, remaining: 4},
+				{allowed: true, remaining: 3},
+				{allowed: true, remaining: 2},
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				{allowed: false, retryAfter: 100 * time.Millisecond},
+				{after: 50 * time.Millisecond, allowed: false, retryAfter: 50 * time.Millisecond},
+			},
+		},
+		{
+			name: "refill one interval at a time",
+			steps: []step{
+				{allowed: true, remaining: 4},
+				{allowed: true, remaining: 3},
+				{allowed: true, remaining: 2},
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				{after: 100 * time.Millisecond, allowed: true, remaining: 0},
+				{after: 250 * time.Millisecond, allowed: true, remaining: 1},
+			},
+		},
+		{
+			name: "idle key refills completely",
+			steps: []step{
+				{allowed: true, remaining: 4},
+				{allowed: true, remaining: 3},
+				{after: time.Hour, allowed: true, remaining: 4},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+			var tat time.Time
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				var res Result
+				tat, res = gcra(limit, now, tat)
+				if res.Allowed != s.allowed || res.Remaining != s.remaining || res.RetryAfter != s.retryAfter {
+					t.Fatalf("step %d: got allowed=%v remaining=%d retryAfter=%v, want %v %d %v",
+						i, res.Allowed, res.Remaining, res.RetryAfter, s.allowed, s.remaining, s.retryAfter)
+				}
+				if res.Limit != limit.Burst {
+					t.Fatalf("step %d: Limit = %d, want %d", i, res.Limit, limit.Burst)
+				}
+				if res.Reset < 0 || res.Reset > limit.tolerance() {
+					t.Fatalf("step %d: Reset = %v outside [0, %v]", i, res.Reset, limit.tolerance())
+				}
+			}
+		})
+	}
+}
+
+// newTestMemory returns a Memory whose clock the test controls.
+func newTestMemory(maxKeys int) (*Memory, *time.Time) {
+	m := NewMemory(maxKeys)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func TestMemoryLRUEviction(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 2}
+	m, _ := newTestMemory(2)
+
+	allow := func(key string) Result {
+		t.Helper()
+		res, err := m.Allow(ctx, key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	allow("a")
+	allow("b")
+	allow("a") // a is now the most recently used, and its bucket is empty
+	allow("c") // evicts b
+	if m.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", m.Len())
+	}
+	if _, ok := m.keys["b"]; ok {
+		t.Fatal("b was not the evicted key")
+	}
+	if res := allow("a"); res.Allowed {
+		t.Fatal("a lost its state although it was recently used")
+	}
+	// the evicted key starts over with a full bucket
+	if res := allow("b"); !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("evicted key: allowed=%v remaining=%d, want a full bucket", res.Allowed, res.Remaining)
+	}
+}
+
+func TestMemoryDropsRefilledKeys(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 2}
+	m, now := newTestMemory(10)
+	for _, key := range []string{"a", "b", "c"} {
+		_, _ = m.Allow(ctx, key, limit)
+	}
+	*now = now.Add(2 * time.Second)
+	_, _ = m.Allow(ctx, "d", limit)
+	if m.Len() != 1 {
+		t.Fatalf("Len = %d, want only d left", m.Len())
+	}
+}
+
+func TestMemoryDeniedUnseenKeyKeepsNoState(t *testing.T) {
+	m, _ := newTestMemory(10)
+	res, _ := m.Allow(context.Background(), "a", Limit{Rate: 1, Burst: 0})
+	if res.Allowed || m.Len() != 0 {
+		t.Fatalf("allowed=%v Len=%d, want a denial and no state", res.Allowed, m.Len())
+	}
+}
+
+// flakyStore fails while down is set and counts its calls.
+type flakyStore struct {
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+func (s *flakyStore) Allow(context.Context, string, Limit) (Result, error) {
+	s.calls.Add(1)
+	if s.down.Load() {
+		return Result{}, errors.New("unreachable")
+	}
+	return Result{Allowed: true, Limit: 100}, nil
+}
+
+func TestFallback(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 1}
+	primary := &flakyStore{}
+	primary.down.Store(true)
+	f := NewFallback(primary, NewMemory(10))
+	f.RetryInterval = 50 * time.Millisecond
+
+	// the local store limits while the primary is down
+	if res, err := f.Allow(ctx, "a", limit); err != nil || !res.Allowed {
+		t.Fatalf("first: %+v %v", res, err)
+	}
+	if res, _ := f.Allow(ctx, "a", limit); res.Allowed {
+		t.Fatal("local store did not limit")
+	}
+	if n := primary.calls.Load(); n != 1 {
+		t.Fatalf("primary asked %d times within RetryInterval, want 1", n)
+	}
+
+	primary.down.Store(false)
+	time.Sleep(f.RetryInterval)
+	if res, _ := f.Allow(ctx, "a", limit); !res.Allowed || res.Limit != 100 {
+		t.Fatalf("after RetryInterval: %+v, want the primary's answer", res)
+	}
+	if f.downUntil.Load() != 0 {
+		t.Fatal("still degraded after the primary recovered")
+	}
+}
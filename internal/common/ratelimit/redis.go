@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript is gcra on the Redis clock, so replicas with skewed clocks
+// still share one bucket. Times are in microseconds; it returns
+// {allowed, tat - now, retry after}.
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then tat = now end
+local next = tat + interval
+local allow_at = next - tolerance
+if now < allow_at then
+  return {0, tat - now, allow_at - now}
+end
+redis.call('SET', KEYS[1], next, 'PX', math.ceil((next - now) / 1000))
+return {1, next - now, 0}
+`)
+
+// Redis shares arrival times between replicas. Keys expire once their
+// bucket has refilled, so Redis holds no state for idle clients.
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedis stores keys as prefix+key.
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+func (r *Redis) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	v, err := gcraScript.Run(ctx, r.client, []string{r.prefix + key},
+		limit.interval().Microseconds(), limit.tolerance().Microseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(v) != 3 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected script reply %v", v)
+	}
+	return result(limit, v[0] == 1, time.Duration(v[1])*time.Microsecond, time.Duration(v[2])*time.Microsecond), nil
+}
+
+// Ping checks that Redis is reachable.
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bucket is the recent activity of one key as seen by this process; with a
+// shared store other replicas keep their own view.
+type Bucket struct {
+	Key       string    `json:"key"`
+	Limiter   string    `json:"limiter"`
+	Allowed   int64     `json:"allowed"`
+	Rejected  int64     `json:"rejected"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Tracker records decisions for the most recently used maxKeys keys.
+type Tracker struct {
+	mu      sync.Mutex
+	maxKeys int
+	keys    map[string]*list.Element
+	lru     *list.List // of *Bucket, front = most recently seen
+}
+
+func NewTracker(maxKeys int) *Tracker {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+	return &Tracker{maxKeys: maxKeys, keys: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Record counts one decision for key.
+func (t *Tracker) Record(limiter, key string, res Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	el, ok := t.keys[key]
+	if ok {
+		t.lru.MoveToFront(el)
+	} else {
+		el = t.lru.PushFront(&Bucket{Key: key, Limiter: limiter})
+		t.keys[key] = el
+		if len(t.keys) > t.maxKeys {
+			old := t.lru.Remove(t.lru.Back()).(*Bucket)
+			delete(t.keys, old.Key)
+		}
+	}
+	b := el.Value.(*Bucket)
+	if res.Allowed {
+		b.Allowed++
+	} else {
+		b.Rejected++
+	}
+	b.Limiter, b.Limit, b.Remaining, b.LastSeen = limiter, res.Limit, res.Remaining, time.Now()
+}
+
+// Buckets returns up to n buckets, most recently seen first; n <= 0 means all.
+func (t *Tracker) Buckets(n int) []Bucket {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Bucket, 0, min(t.lru.Len(), max(n, 0)))
+	for el := t.lru.Front(); el != nil && (n <= 0 || len(out) < n); el = el.Next() {
+		out = append(out, *el.Value.(*Bucket))
+	}
+	return out
+}
+
+// TopOffenders returns up to n buckets with rejections, most rejected first.
+func (t *Tracker) TopOffenders(n int) []Bucket {
+	var out []Bucket
+	for _, b := range t.Buckets(0) {
+		if b.Rejected > 0 {
+			out = append(out, b)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Rejected > out[j].Rejected })
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package response
+
+import (
+	"cmp"
+	"encoding/xml"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+	"google.golang.org/protobuf/proto"
+)
+
+// Wire formats a port may allow, see Formats.
+const (
+	FormatJSON     = "json"
+	FormatMsgPack  = "msgpack"
+	FormatProtobuf = "protobuf"
+	FormatXML      = "xml"
+)
+
+const formatsKey = "formats"
+
+// mimeFormats maps the media types accepted in Accept and Content-Type.
+var mimeFormats = map[string]string{
+	binding.MIMEJSON:       FormatJSON,
+	binding.MIMEMSGPACK:    FormatMsgPack,
+	binding.MIMEMSGPACK2:   FormatMsgPack,
+	binding.MIMEPROTOBUF:   FormatProtobuf,
+	"application/protobuf": FormatProtobuf,
+	binding.MIMEXML:        FormatXML,
+	binding.MIMEXML2:       FormatXML,
+}
+
+// offered lists response media types in server preference order; JSON
+// first, so it wins for */* and missing Accept headers.
+var offered = []string{
+	binding.MIMEJSON,
+	binding.MIMEMSGPACK2, binding.MIMEMSGPACK,
+	binding.MIMEPROTOBUF, "application/protobuf",
+	binding.MIMEXML, binding.MIMEXML2,
+}
+
+// Formats sets the wire formats a port allows besides JSON, which is always
+// allowed and the fallback when Accept names nothing allowed.
+func Formats(allowed []string) gin.HandlerFunc {
+	set := map[string]bool{FormatJSON: true}
+	for _, f := range allowed {
+		set[f] = true
+	}
+	return func(c *gin.Context) {
+		c.Set(formatsKey, set)
+		c.Next()
+	}
+}
+
+// Allowed reports whether the port serving c allows format.
+func Allowed(c *gin.Context, format string) bool {
+	if format == FormatJSON {
+		return true
+	}
+	set, _ := c.Get(formatsKey)
+	allowed, _ := set.(map[string]bool)
+	return allowed[format]
+}
+
+// FormatOf maps a media type (without parameters) to its format, or "".
+func FormatOf(mediaType string) string { return mimeFormats[mediaType] }
+
+// negotiate picks the response format for body: the allowed format Accept
+// rates highest that can encode it, JSON otherwise. Protobuf needs a
+// proto.Message payload; XML falls back to JSON in write.
+func negotiate(c *gin.Context, body any) string {
+	candidates := make([]string, 0, len(offered))
+	for _, mt := range offered {
+		f := mimeFormats[mt]
+		if !Allowed(c, f) {
+			continue
+		}
+		if _, ok := protoOf(body); f == FormatProtobuf && !ok {
+			continue
+		}
+		candidates = append(candidates, mt)
+	}
+	if f := mimeFormats[preferred(c.GetHeader("Accept"), candidates)]; f != "" {
+		return f
+	}
+	return FormatJSON
+}
+
+// preferred returns the candidate media type Accept rates highest, ties
+// going to the earlier one in Accept; the first candidate when Accept is
+// empty, "" when it accepts none. gin's NegotiateFormat ignores q values.
+func preferred(accept string, candidates []string) string {
+	if strings.TrimSpace(accept) == "" {
+		if len(candidates) == 0 {
+			return ""
+		}
+		return candidates[0]
+	}
+	type rated struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []rated
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, rated{mt, q})
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b rated) int { return cmp.Compare(b.q, a.q) })
+	for _, r := range ranges {
+		for _, mt := range candidates {
+			typ, _, _ := strings.Cut(mt, "/")
+			if r.mediaType == mt || r.mediaType == "*/*" || r.mediaType == typ+"/*" {
+				return mt
+			}
+		}
+	}
+	return ""
+}
+
+// protoOf returns body, or the data of a SuccessResponse, as a proto.Message.
+func protoOf(body any) (proto.Message, bool) {
+	if p, ok := body.(interface{ payload() any }); ok {
+		body = p.payload()
+	}
+	m, ok := body.(proto.Message)
+	return m, ok
+}
+
+// write encodes body in the negotiated format.
+func write(c *gin.Context, status int, body any) {
+	switch negotiate(c, body) {
+	case FormatMsgPack:
+		c.Render(status, render.MsgPack{Data: body})
+	case FormatProtobuf:
+		m, _ := protoOf(body)
+		c.ProtoBuf(status, m)
+	case FormatXML:
+		// encoding/xml cannot encode maps (gin.H aside); answer JSON rather
+		// than a body cut off after the status line
+		b, err := xml.Marshal(body)
+		if err != nil {
+			c.JSON(status, body)
+			return
+		}
+		c.Data(status, binding.MIMEXML+"; charset=utf-8", b)
+	default:
+		c.JSON(status, body)
+	}
+}
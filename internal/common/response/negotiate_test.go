@@ -0,0 +1,153 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+)
+
+type xmlItem struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestWriteFormats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		formats    []string
+		accept     string
+		handler    gin.HandlerFunc
+		wantType   string
+		wantInBody string
+	}{
+		{
+			name:       "xml struct",
+			formats:    []string{FormatXML},
+			accept:     "application/xml",
+			handler:    func(c *gin.Context) { OK(c, xmlItem{Name: "a"}) },
+			wantType:   "application/xml",
+			wantInBody: "<response><msg>ok</msg><data><name>a</name></data></response>",
+		},
+		{
+			name:       "xml map falls back to json",
+			formats:    []string{FormatXML},
+			accept:     "application/xml",
+			handler:    func(c *gin.Context) { OK(c, map[string]string{"usersvc": "debug"}) },
+			wantType:   "application/json",
+			wantInBody: `"data":{"usersvc":"debug"}`,
+		},
+		{
+			name:    "xml error details map falls back to json",
+			formats: []string{FormatXML},
+			accept:  "application/xml",
+			handler: func(c *gin.Context) {
+				Fail(c, apperr.BadRequest.WithDetails(map[string]any{"field": "name"}))
+			},
+			wantType:   "application/json",
+			wantInBody: `"field":"name"`,
+		},
+		{
+			name:       "xml not allowed",
+			accept:     "application/xml",
+			handler:    func(c *gin.Context) { OK(c, xmlItem{Name: "a"}) },
+			wantType:   "application/json",
+			wantInBody: `"name":"a"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			e.GET("/", Formats(tt.formats), tt.handler)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.wantType) {
+				t.Errorf("Content-Type = %q, want %s", ct, tt.wantType)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantInBody) {
+				t.Errorf("body = %s, want it to contain %s", w.Body, tt.wantInBody)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	all := []string{FormatMsgPack, FormatProtobuf, FormatXML}
+	item := func(c *gin.Context) { OK(c, xmlItem{Name: "a"}) }
+	message := func(c *gin.Context) { OK(c, wrapperspb.String("a")) }
+	tests := []struct {
+		name     string
+		formats  []string
+		accept   string
+		handler  gin.HandlerFunc
+		wantType string
+	}{
+		{"no Accept", all, "", item, "application/json"},
+		{"any", all, "*/*", item, "application/json"},
+		{"msgpack", all, "application/msgpack", item, "application/msgpack"},
+		{"x-msgpack", all, "application/x-msgpack", item, "application/msgpack"},
+		{"xml", all, "text/xml", item, "application/xml"},
+		{"by quality", all, "application/msgpack;q=0.5, application/xml", item, "application/xml"},
+		{"type wildcard ranked lower", all, "application/*;q=0.2, text/xml", item, "application/xml"},
+		{"refused with q=0", all, "application/msgpack;q=0, application/xml;q=0", item, "application/json"},
+		{"not allowed", []string{FormatXML}, "application/msgpack", item, "application/json"},
+		{"protobuf", all, "application/x-protobuf", message, "application/x-protobuf"},
+		{"protobuf of a struct", all, "application/x-protobuf", item, "application/json"},
+		{"protobuf of a struct, then xml", all, "application/x-protobuf, application/xml;q=0.5", item, "application/xml"},
+		{"protobuf not allowed", []string{FormatMsgPack}, "application/x-protobuf", message, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			e.GET("/", Formats(tt.formats), tt.handler)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.wantType) {
+				t.Fatalf("Content-Type = %q, want %s", ct, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestWriteBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(accept string, handler gin.HandlerFunc) []byte {
+		e := gin.New()
+		e.GET("/", Formats([]string{FormatMsgPack, FormatProtobuf}), handler)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w.Body.Bytes()
+	}
+
+	var got map[string]any
+	h := &codec.MsgpackHandle{}
+	h.RawToString = true
+	body := serve("application/msgpack", func(c *gin.Context) { OK(c, xmlItem{Name: "a"}) })
+	if err := codec.NewDecoderBytes(body, h).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := got["data"].(map[any]any); got["msg"] != "ok" || data["name"] != "a" {
+		t.Fatalf("msgpack body = %v, want the envelope", got)
+	}
+
+	// protobuf drops the envelope
+	var m wrapperspb.StringValue
+	body = serve("application/x-protobuf", func(c *gin.Context) { OK(c, wrapperspb.String("a")) })
+	if err := proto.Unmarshal(body, &m); err != nil || m.GetValue() != "a" {
+		t.Fatalf("protobuf body = %q, %v", body, err)
+	}
+}
@@ -0,0 +1,71 @@
+package response
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+)
+
+// Error body formats, see ErrorFormat.
+const (
+	FormatEnvelope = "envelope"
+	FormatProblem  = "problem"
+
+	ProblemContentType = "application/problem+json"
+)
+
+const errorFormatKey = "error_format"
+
+// Problem is an RFC 7807 problem details document. Code, RequestID and
+// Errors are extension members.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	Errors    any    `json:"errors,omitempty"` // apperr details, e.g. field validation errors
+}
+
+// ErrorFormat sets the error body format of a port: FormatEnvelope (also
+// for "") or FormatProblem. A request whose Accept lists
+// application/problem+json always gets a problem document.
+func ErrorFormat(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(errorFormatKey, format)
+		c.Next()
+	}
+}
+
+func wantsProblem(c *gin.Context) bool {
+	if c.GetString(errorFormatKey) == FormatProblem {
+		return true
+	}
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mt == ProblemContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// problemOf renders e with type about:blank, so the title is the HTTP
+// status text and detail the user-facing message.
+func problemOf(c *gin.Context, e *apperr.Error) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Message,
+		Instance:  c.Request.URL.Path,
+		Code:      e.Code,
+		RequestID: c.GetString("request_id"),
+		Errors:    e.Details,
+	}
+}
@@ -0,0 +1,124 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+)
+
+func TestFailFormats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	details := []map[string]string{{"field": "login_id"}}
+	tests := []struct {
+		name        string
+		format      string // ErrorFormat of the port; "-" = middleware not used
+		accept      string
+		wantProblem bool
+	}{
+		{name: "envelope by default", format: "-"},
+		{name: "empty format", format: ""},
+		{name: "envelope format", format: FormatEnvelope},
+		{name: "problem format", format: FormatProblem, wantProblem: true},
+		{name: "problem format with Accept json", format: FormatProblem, accept: "application/json", wantProblem: true},
+		{name: "asked for by Accept", format: "-", accept: ProblemContentType, wantProblem: true},
+		{name: "asked for among others", format: FormatEnvelope, accept: "application/json, application/problem+json;q=0.9", wantProblem: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			e.Use(func(c *gin.Context) { c.Set("request_id", "req-1") })
+			if tt.format != "-" {
+				e.Use(ErrorFormat(tt.format))
+			}
+			e.GET("/users/:id", func(c *gin.Context) {
+				Fail(c, apperr.NotFound.WithMessage("user 7 not found").WithDetails(details))
+			})
+			req := httptest.NewRequest(http.MethodGet, "/users/7?x=1", nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404", w.Code)
+			}
+			ct := w.Header().Get("Content-Type")
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %s: %v", w.Body, err)
+			}
+			if !tt.wantProblem {
+				if !strings.HasPrefix(ct, "application/json") {
+					t.Fatalf("Content-Type = %q, want application/json", ct)
+				}
+				want := map[string]any{"code": "common.not_found", "msg": "user 7 not found", "request_id": "req-1"}
+				for k, v := range want {
+					if body[k] != v {
+						t.Errorf("%s = %v, want %v", k, body[k], v)
+					}
+				}
+				if _, ok := body["type"]; ok || body["data"] == nil {
+					t.Errorf("body = %s, want the envelope with details in data", w.Body)
+				}
+				return
+			}
+			if ct != ProblemContentType {
+				t.Fatalf("Content-Type = %q, want %s", ct, ProblemContentType)
+			}
+			want := map[string]any{
+				"type":       "about:blank",
+				"title":      "Not Found",
+				"status":     float64(http.StatusNotFound),
+				"detail":     "user 7 not found",
+				"instance":   "/users/7",
+				"code":       "common.not_found",
+				"request_id": "req-1",
+			}
+			for k, v := range want {
+				if body[k] != v {
+					t.Errorf("%s = %v, want %v", k, body[k], v)
+				}
+			}
+			if errs, _ := body["errors"].([]any); len(errs) != 1 {
+				t.Errorf("errors = %v, want the details", body["errors"])
+			}
+			if _, ok := body["msg"]; ok {
+				t.Errorf("body = %s, want no envelope fields", w.Body)
+			}
+		})
+	}
+}
+
+func TestProblemFormatKeepsSuccessEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", ErrorFormat(FormatProblem), func(c *gin.Context) { OK(c, "fine") })
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if ct := w.Header().Get("Content-Type"); w.Code != http.StatusOK || !strings.HasPrefix(ct, "application/json") ||
+		w.Body.String() != `{"msg":"ok","data":"fine"}` {
+		t.Fatalf("answer = %d %q %s, want the success envelope", w.Code, ct, w.Body)
+	}
+}
+
+func TestProblemOmitsEmptyMembers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.GET("/", ErrorFormat(FormatProblem), func(c *gin.Context) { Fail(c, apperr.Conflict) })
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"errors", "request_id"} {
+		if _, ok := body[k]; ok {
+			t.Errorf("body = %s, want no %s", w.Body, k)
+		}
+	}
+}
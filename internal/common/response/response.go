@@ -1,48 +1,97 @@
 package response
 
 import (
+	"encoding/xml"
+
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/wiidz/gin_template/internal/common/apperr"
 	"github.com/wiidz/gin_template/internal/common/logger"
 )
 
 type SuccessResponse[T any] struct {
-	Msg  string `json:"msg"`
-	Data T      `json:"data"`
+	XMLName xml.Name `json:"-" xml:"response"`
+	Msg     string   `json:"msg" xml:"msg"`
+	Data    T        `json:"data" xml:"data"`
 }
 
+// payload lets protobuf responses drop the envelope, see Respond.
+func (r SuccessResponse[T]) payload() any { return r.Data }
+
+// Page is the standard envelope for paginated lists.
+type Page[T any] struct {
+	Total    int64 `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+	Items    []T   `json:"items"`
+}
+
+// ErrorResponse keeps the {msg, data} envelope and adds the stable error
+// code (see apperr) and the request ID to quote in bug reports. Data holds
+// the error's details, if any.
 type ErrorResponse struct {
-	Msg  string      `json:"msg"`
-	Data interface{} `json:"data"`
+	XMLName   xml.Name    `json:"-" xml:"error"`
+	Code      string      `json:"code" xml:"code"`
+	Msg       string      `json:"msg" xml:"msg"`
+	Data      interface{} `json:"data" xml:"data,omitempty"`
+	RequestID string      `json:"request_id,omitempty" xml:"request_id,omitempty"`
 }
 
 func JSON(c *gin.Context, status int, data any) {
 	c.JSON(status, data)
 }
 
+// Respond writes data in the format negotiated from Accept among those the
+// port allows (see Formats): JSON, MessagePack, XML, or protobuf when the
+// payload is a proto.Message. Protobuf bodies are the bare message, without
+// the {msg, data} envelope.
+func Respond(c *gin.Context, status int, data any) {
+	write(c, status, data)
+}
+
 func OK[T any](c *gin.Context, data T) {
-	c.JSON(200, SuccessResponse[T]{Msg: "ok", Data: data})
+	write(c, 200, SuccessResponse[T]{Msg: "ok", Data: data})
 }
 
 func OKMsg[T any](c *gin.Context, msg string, data T) {
-	c.JSON(200, SuccessResponse[T]{Msg: msg, Data: data})
+	write(c, 200, SuccessResponse[T]{Msg: msg, Data: data})
 }
 
+// Error responds with the common error for status and msg as the message.
+// Prefer Fail with a catalogued error.
 func Error(c *gin.Context, status int, msg string) {
-	// Structured warn log for non-200 responses
-	portVal, _ := c.Get("port")
-	port, _ := portVal.(string)
+	Fail(c, apperr.ForStatus(status).WithMessage(msg))
+}
+
+// Fail converts err with apperr.From and responds with its code, status and
+// user-facing message, as an ErrorResponse or an RFC 7807 Problem (see
+// ErrorFormat). The internal cause is only logged: at error level for 5xx,
+// warn otherwise.
+func Fail(c *gin.Context, err error) {
+	e := apperr.From(err)
+	// request fields come from the context logger (see middleware.RequestLogger)
 	fields := []zap.Field{
-		zap.Int("status", status),
+		zap.String("code", e.Code),
+		zap.Int("status", e.Status),
 		zap.String("method", c.Request.Method),
 		zap.String("path", c.Request.URL.Path),
 	}
-	if port != "" {
-		fields = append(fields, zap.String("port", port))
+	if e.Cause != nil {
+		fields = append(fields, zap.Error(e.Cause))
+	}
+	l := logger.FromContext(c.Request.Context())
+	if e.Status >= 500 {
+		l.Error("http_error", fields...)
+	} else {
+		l.Warn("http_error", fields...)
 	}
-	logger.L.Warn("http_error", fields...)
 
-	c.JSON(status, ErrorResponse{Msg: msg, Data: nil})
+	if wantsProblem(c) {
+		c.Header("Content-Type", ProblemContentType)
+		c.JSON(e.Status, problemOf(c, e))
+	} else {
+		write(c, e.Status, ErrorResponse{Code: e.Code, Msg: e.Message, Data: e.Details, RequestID: c.GetString("request_id")})
+	}
 	c.Abort()
 }
@@ -0,0 +1,52 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+)
+
+func TestFailHidesCauses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secret = "password authentication failed for user app"
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"unknown error", errors.New(secret), http.StatusInternalServerError, "common.internal"},
+		{"wrapped unknown error", fmt.Errorf("load: %w", errors.New(secret)), http.StatusInternalServerError, "common.internal"},
+		{"catalog error with a cause", apperr.NotFound.WithCause(errors.New(secret)), http.StatusNotFound, "common.not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			e.GET("/", func(c *gin.Context) { Fail(c, tt.err) })
+			for _, accept := range []string{"", ProblemContentType} {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept", accept)
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, req)
+
+				if w.Code != tt.wantStatus || strings.Contains(w.Body.String(), secret) {
+					t.Fatalf("Accept %q: answer = %d %s, want %d without the cause", accept, w.Code, w.Body, tt.wantStatus)
+				}
+				var body struct {
+					Code string `json:"code"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != tt.wantCode {
+					t.Fatalf("Accept %q: body %s, want code %s", accept, w.Body, tt.wantCode)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// GormPlugin starts a client span for every statement run with a context,
+// e.g. through repoMng, as a child of the span in that context.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string { return "tracing" }
+
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	tracer := Tracer("gorm")
+	before := func(op string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			ctx := tx.Statement.Context
+			if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+				return // only trace statements that belong to a traced request
+			}
+			ctx, span := tracer.Start(ctx, "gorm."+op, trace.WithSpanKind(trace.SpanKindClient))
+			tx.Statement.Context = ctx
+			tx.InstanceSet(gormSpanKey, span)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(gormSpanKey)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		attrs := []attribute.KeyValue{
+			semconv.DBSystemNamePostgreSQL,
+			// placeholders only; bound values may hold secrets such as password hashes
+			semconv.DBQueryText(tx.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+		}
+		if tx.Statement.Table != "" {
+			attrs = append(attrs, semconv.DBCollectionName(tx.Statement.Table))
+		}
+		span.SetAttributes(attrs...)
+		if err := tx.Error; err != nil && err != gorm.ErrRecordNotFound {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+
+	cb := db.Callback()
+	for _, h := range []struct {
+		op     string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	} {
+		if err := h.before("tracing:before_"+h.op, before(h.op)); err != nil {
+			return err
+		}
+		if err := h.after("tracing:after_"+h.op, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
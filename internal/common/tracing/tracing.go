@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Exporter names accepted by Options.Exporter.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterMemory = "memory"
+)
+
+type Options struct {
+	ServiceName string
+	Exporter    string  // none (default), otlp, stdout or memory
+	Endpoint    string  // OTLP/HTTP host:port; empty uses OTEL_EXPORTER_OTLP_* env
+	Insecure    bool    // OTLP over plain HTTP
+	SampleRatio float64 // fraction of new traces kept; parent decisions are honoured
+}
+
+var (
+	mu     sync.Mutex
+	memory *tracetest.InMemoryExporter
+)
+
+// Init installs the global tracer provider and the W3C traceparent/baggage
+// propagator. The propagator is installed even with the none exporter so
+// incoming trace context is still forwarded. The returned func flushes and
+// stops the exporter.
+func Init(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	var (
+		exp        sdktrace.SpanExporter
+		syncExport bool
+	)
+	switch strings.ToLower(opts.Exporter) {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		e, err := otlptracehttp.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: otlp exporter: %w", err)
+		}
+		exp = e
+	case ExporterStdout:
+		e, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("tracing: stdout exporter: %w", err)
+		}
+		exp = e
+	case ExporterMemory:
+		mu.Lock()
+		memory = tracetest.NewInMemoryExporter()
+		exp = memory
+		mu.Unlock()
+		// synchronous so spans are visible as soon as they end
+		syncExport = true
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", opts.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: resource: %w", err)
+	}
+	var processor sdktrace.SpanProcessor
+	if syncExport {
+		processor = sdktrace.NewSimpleSpanProcessor(exp)
+	} else {
+		processor = sdktrace.NewBatchSpanProcessor(exp)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Memory returns the in-memory exporter installed by Init with the memory
+// exporter, or nil.
+func Memory() *tracetest.InMemoryExporter {
+	mu.Lock()
+	defer mu.Unlock()
+	return memory
+}
+
+// Tracer returns a tracer from the global provider.
+func Tracer(name string) trace.Tracer { return otel.Tracer(name) }
+
+// End records err on span, if any, and ends it. Use with a named error
+// result: defer func() { tracing.End(span, err) }().
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// LogFields returns trace_id/span_id zap fields for the span in ctx, or none.
+func LogFields(ctx context.Context) []zap.Field {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []zap.Field{
+		zap.String("trace_id", sc.TraceID().String()),
+		zap.String("span_id", sc.SpanID().String()),
+	}
+}
@@ -13,14 +13,13 @@ import (
 	idmng "github.com/wiidz/goutil/mngs/identityMng"
 )
 
-func BuildEngine() *gin.Engine {
-	e := gin.New()
-
+// Routes registers the client port's handlers.
+func Routes(e *gin.Engine) {
 	// repos.Setup 应在 server/main 处传入
 	// 通用仓储直接传入 service
 	uRepo := repos.User.Repo
 	mng, _ := idmng.NewMng(&idmng.Config{DefaultDevice: "client"})
-	uSvc := usersvc.New(uRepo, mng)
+	uSvc := usersvc.New(uRepo, usersvc.NewTokenStore(repos.User.DB), mng)
 	clientH := userhandler.NewClientHandler(uSvc)
 
 	e.GET("/health", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
@@ -29,5 +28,9 @@ func BuildEngine() *gin.Engine {
 	v1 := e.Group("/api/v1")
 	v1.GET("/ping", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"message": "pong"}) })
 	v1.Group("").Use(sagin.CheckLogin()).GET("/user/me", clientH.Me)
-	return e
+
+	auth := v1.Group("/auth")
+	auth.POST("/login", clientH.Login)
+	auth.POST("/refresh", clientH.Refresh)
+	auth.Group("").Use(sagin.CheckLogin()).POST("/logout", clientH.Logout)
 }
@@ -1,12 +1,10 @@
 package user
 
 import (
-	"net/http"
-
 	"github.com/gin-gonic/gin"
-	"github.com/wiidz/goutil/helpers/paramHelper"
 	"github.com/wiidz/goutil/structs/networkStruct"
 
+	"github.com/wiidz/gin_template/internal/common/binding"
 	"github.com/wiidz/gin_template/internal/common/response"
 	"github.com/wiidz/gin_template/internal/domain/shared/user/dto"
 	usersvc "github.com/wiidz/gin_template/internal/domain/shared/user/service"
@@ -18,21 +16,33 @@ func NewClientHandler(s *usersvc.Service) *ClientHandler { return &ClientHandler
 
 func (h *ClientHandler) Login(c *gin.Context) {
 	var req dto.LoginRequest
-	if err := paramHelper.BuildParams(c.Request, &req, networkStruct.BodyJson); err != nil {
-		response.Error(c, http.StatusBadRequest, err.Error())
+	if !binding.Bind(c, &req, networkStruct.BodyJson) {
 		return
 	}
 	pair, err := h.S.Login(c.Request.Context(), req)
 	if err != nil {
-		response.Error(c, http.StatusUnauthorized, err.Error())
+		response.Fail(c, err)
+		return
+	}
+	response.OK(c, pair)
+}
+
+func (h *ClientHandler) Refresh(c *gin.Context) {
+	var req dto.RefreshRequest
+	if !binding.Bind(c, &req, networkStruct.BodyJson) {
+		return
+	}
+	pair, err := h.S.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		response.Fail(c, err)
 		return
 	}
 	response.OK(c, pair)
 }
 
 func (h *ClientHandler) Logout(c *gin.Context) {
-	if err := h.S.Logout(c.Request.Context()); err != nil {
-		response.Error(c, http.StatusInternalServerError, err.Error())
+	if err := h.S.Logout(c.Request.Context(), accessToken(c)); err != nil {
+		response.Fail(c, err)
 		return
 	}
 	response.OK(c, gin.H{"ok": true})
@@ -41,3 +51,11 @@ func (h *ClientHandler) Logout(c *gin.Context) {
 func (h *ClientHandler) Me(c *gin.Context) {
 	response.OK(c, gin.H{"login_id": h.S.CurrentLoginID(c.Request.Context())})
 }
+
+// accessToken reads the token the same way sa-token's gin middleware does.
+func accessToken(c *gin.Context) string {
+	if token := c.GetHeader("Authorization"); token != "" {
+		return token
+	}
+	return c.GetHeader("satoken")
+}
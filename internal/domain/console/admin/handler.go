@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wiidz/goutil/structs/networkStruct"
+	"go.uber.org/zap"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+	"github.com/wiidz/gin_template/internal/common/binding"
+	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/response"
+)
+
+// LogLevelRequest changes the root level (logger empty) or one named
+// logger, e.g. {"logger": "repo", "level": "debug"}. An empty level restores
+// the env default for the root and removes a named override.
+type LogLevelRequest struct {
+	networkStruct.Params `swaggerignore:"true"`
+
+	Logger string `json:"logger" belong:"value"`
+	Level  string `json:"level" belong:"value" validate:"omitempty,oneof=debug info warn error"`
+}
+
+// LogLevels is the current level state.
+type LogLevels struct {
+	Level   string            `json:"level"`
+	Loggers map[string]string `json:"loggers"`
+}
+
+// GetLogLevel serves GET /admin/log-level.
+func GetLogLevel(c *gin.Context) {
+	response.OK(c, currentLevels())
+}
+
+// PutLogLevel serves PUT /admin/log-level. Changes last until the next
+// config reload that touches log.level or log.levels.
+func PutLogLevel(c *gin.Context) {
+	var req LogLevelRequest
+	if !binding.Bind(c, &req, networkStruct.BodyJson) {
+		return
+	}
+	if err := logger.SetLoggerLevel(req.Logger, req.Level); err != nil {
+		response.Fail(c, apperr.BadRequest.WithMessage(err.Error()))
+		return
+	}
+	logger.FromContext(c.Request.Context()).Info("log_level_changed",
+		zap.String("logger", req.Logger), zap.String("level", req.Level))
+	response.OK(c, currentLevels())
+}
+
+func currentLevels() LogLevels {
+	out := LogLevels{Level: logger.Level().String(), Loggers: map[string]string{}}
+	for name, lvl := range logger.Levels() {
+		out.Loggers[name] = lvl.String()
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/common/logger"
+)
+
+func TestPutLogLevel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	level, levels := logger.Level(), logger.Levels()
+	t.Cleanup(func() {
+		_ = logger.SetLevel(level.String())
+		named := map[string]string{}
+		for k, v := range levels {
+			named[k] = v.String()
+		}
+		_ = logger.SetLevels(named)
+	})
+	_ = logger.SetLevel("info")
+	_ = logger.SetLevels(nil)
+
+	e := gin.New()
+	e.GET("/admin/log-level", GetLogLevel)
+	e.PUT("/admin/log-level", PutLogLevel)
+	do := func(method, body string) (int, LogLevels) {
+		req := httptest.NewRequest(method, "/admin/log-level", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		var res struct {
+			Data LogLevels `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res.Data
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantLevel   string
+		wantLoggers map[string]string
+	}{
+		{"named logger", `{"logger":"repo","level":"debug"}`, http.StatusOK, "info", map[string]string{"repo": "debug"}},
+		{"root", `{"level":"warn"}`, http.StatusOK, "warn", map[string]string{"repo": "debug"}},
+		{"remove an override", `{"logger":"repo"}`, http.StatusOK, "warn", map[string]string{}},
+		{"unknown level", `{"logger":"repo","level":"loud"}`, http.StatusBadRequest, "warn", map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := do(http.MethodPut, tt.body); status != tt.wantStatus {
+				t.Fatalf("PUT status = %d, want %d", status, tt.wantStatus)
+			}
+			_, got := do(http.MethodGet, "")
+			if got.Level != tt.wantLevel || len(got.Loggers) != len(tt.wantLoggers) {
+				t.Fatalf("levels = %+v, want %s %v", got, tt.wantLevel, tt.wantLoggers)
+			}
+			for k, v := range tt.wantLoggers {
+				if got.Loggers[k] != v {
+					t.Fatalf("levels = %+v, want %s %v", got, tt.wantLevel, tt.wantLoggers)
+				}
+			}
+		})
+	}
+}
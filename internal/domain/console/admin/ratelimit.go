@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+	"github.com/wiidz/gin_template/internal/common/middleware"
+	"github.com/wiidz/gin_template/internal/common/ratelimit"
+	"github.com/wiidz/gin_template/internal/common/response"
+)
+
+// RateLimits is this replica's view of rate limiting: with the redis store
+// the limits are shared but the activity is per replica.
+type RateLimits struct {
+	Policies     []middleware.RateLimitPolicy `json:"policies"`
+	Buckets      []ratelimit.Bucket           `json:"buckets"`       // most recently used first
+	TopOffenders []ratelimit.Bucket           `json:"top_offenders"` // most rejected first
+}
+
+// GetRateLimits serves GET /admin/rate-limits?limit=50.
+func GetRateLimits(c *gin.Context) {
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			response.Fail(c, apperr.BadRequest.WithMessage("limit must be between 1 and 1000"))
+			return
+		}
+		limit = n
+	}
+	policies := middleware.CurrentRateLimitPolicies()
+	if policies == nil {
+		policies = []middleware.RateLimitPolicy{}
+	}
+	response.OK(c, RateLimits{
+		Policies:     policies,
+		Buckets:      middleware.RateLimitBuckets(limit),
+		TopOffenders: nonNil(middleware.RateLimitOffenders(limit)),
+	})
+}
+
+func nonNil(b []ratelimit.Bucket) []ratelimit.Bucket {
+	if b == nil {
+		return []ratelimit.Bucket{}
+	}
+	return b
+}
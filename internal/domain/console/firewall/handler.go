@@ -0,0 +1,175 @@
+package firewall
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wiidz/goutil/structs/networkStruct"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+	"github.com/wiidz/gin_template/internal/common/binding"
+	"github.com/wiidz/gin_template/internal/common/firewall"
+	"github.com/wiidz/gin_template/internal/common/middleware"
+	"github.com/wiidz/gin_template/internal/common/response"
+	"github.com/wiidz/gin_template/internal/domain/shared/firewall/dto"
+	"github.com/wiidz/gin_template/internal/domain/shared/firewall/entity"
+	fwsvc "github.com/wiidz/gin_template/internal/domain/shared/firewall/service"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type ConsoleHandler struct{ S *fwsvc.Service }
+
+func NewConsoleHandler(s *fwsvc.Service) *ConsoleHandler { return &ConsoleHandler{S: s} }
+
+// RulesView lists the stored rules, which can be removed by ID, and every
+// rule in effect on this replica, including those from config.
+type RulesView struct {
+	Rules     []dto.RuleView  `json:"rules"`
+	Effective []firewall.Rule `json:"effective"`
+}
+
+// List serves GET /admin/firewall/rules.
+func (h *ConsoleHandler) List(c *gin.Context) {
+	rows, err := h.S.List(c.Request.Context())
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	out := RulesView{Rules: make([]dto.RuleView, 0, len(rows)), Effective: firewall.Rules()}
+	for _, r := range rows {
+		out.Rules = append(out.Rules, toRuleView(r))
+	}
+	if out.Effective == nil {
+		out.Effective = []firewall.Rule{}
+	}
+	response.OK(c, out)
+}
+
+// Add serves POST /admin/firewall/rules, e.g.
+// {"action":"deny","cidr":"203.0.113.0/24","reason":"scanner","ttl":"24h"}.
+func (h *ConsoleHandler) Add(c *gin.Context) {
+	var req dto.AddRuleRequest
+	if !binding.Bind(c, &req, networkStruct.BodyJson) {
+		return
+	}
+	r, err := h.S.Add(c.Request.Context(), req, actorOf(c))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Respond(c, http.StatusCreated, response.SuccessResponse[dto.RuleView]{Msg: "ok", Data: toRuleView(r)})
+}
+
+// Remove serves DELETE /admin/firewall/rules/:id.
+func (h *ConsoleHandler) Remove(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		response.Fail(c, apperr.BadRequest.WithMessage("invalid id"))
+		return
+	}
+	if err := h.S.Remove(c.Request.Context(), id, actorOf(c)); err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.OK(c, gin.H{"ok": true})
+}
+
+// Audit serves GET /admin/firewall/audit?page=&page_size=&rule_id=.
+func (h *ConsoleHandler) Audit(c *gin.Context) {
+	q, err := parseAuditQuery(c)
+	if err != nil {
+		response.Fail(c, apperr.BadRequest.WithMessage(err.Error()))
+		return
+	}
+	rows, total, err := h.S.Audit(c.Request.Context(), q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	items := make([]dto.AuditView, 0, len(rows))
+	for _, a := range rows {
+		items = append(items, toAuditView(a))
+	}
+	response.OK(c, response.Page[dto.AuditView]{Total: total, Page: q.Page, PageSize: q.PageSize, Items: items})
+}
+
+// Bans serves GET /admin/firewall/bans: the automatic bans in force on
+// this replica.
+func (h *ConsoleHandler) Bans(c *gin.Context) {
+	response.OK(c, firewall.Bans())
+}
+
+// Unban serves DELETE /admin/firewall/bans/:ip, lifting an automatic ban on
+// this replica.
+func (h *ConsoleHandler) Unban(c *gin.Context) {
+	if !firewall.Unban(c.Request.Context(), c.Param("ip")) {
+		response.Fail(c, apperr.NotFound.WithMessage("no automatic ban for this IP"))
+		return
+	}
+	response.OK(c, gin.H{"ok": true})
+}
+
+func actorOf(c *gin.Context) dto.Actor {
+	return dto.Actor{LoginID: middleware.LoginID(c), IP: c.ClientIP(), Port: c.GetString("port")}
+}
+
+func parseAuditQuery(c *gin.Context) (dto.ListAuditQuery, error) {
+	q := dto.ListAuditQuery{Page: 1, PageSize: defaultPageSize}
+	if v := c.Query("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return q, errors.New("page must be a positive integer")
+		}
+		q.Page = n
+	}
+	if v := c.Query("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return q, errors.New("page_size must be between 1 and 100")
+		}
+		q.PageSize = n
+	}
+	if v := c.Query("rule_id"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return q, errors.New("rule_id must be a positive integer")
+		}
+		q.RuleID = n
+	}
+	return q, nil
+}
+
+func toRuleView(r *entity.FirewallRuleEntity) dto.RuleView {
+	return dto.RuleView{
+		ID:        r.ID,
+		Action:    r.Action,
+		CIDR:      r.CIDR,
+		Port:      r.Port,
+		Reason:    r.Reason,
+		ExpiresAt: r.ExpiresAt,
+		CreatedBy: r.CreatedBy,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+func toAuditView(a *entity.FirewallAuditEntity) dto.AuditView {
+	return dto.AuditView{
+		ID:        a.ID,
+		RuleID:    a.RuleID,
+		Op:        a.Op,
+		Action:    a.Action,
+		CIDR:      a.CIDR,
+		Port:      a.Port,
+		Reason:    a.Reason,
+		ExpiresAt: a.ExpiresAt,
+		Actor:     a.Actor,
+		ActorIP:   a.ActorIP,
+		CreatedAt: a.CreatedAt,
+	}
+}
@@ -6,24 +6,48 @@ import (
 	sagin "github.com/click33/sa-token-go/integrations/gin"
 	"github.com/gin-gonic/gin"
 
+	"github.com/wiidz/gin_template/internal/base/app"
 	"github.com/wiidz/gin_template/internal/base/repos"
+	"github.com/wiidz/gin_template/internal/common/metrics"
+	"github.com/wiidz/gin_template/internal/common/middleware"
+	"github.com/wiidz/gin_template/internal/domain/console/admin"
+	fwhandler "github.com/wiidz/gin_template/internal/domain/console/firewall"
 	userhandler "github.com/wiidz/gin_template/internal/domain/console/user"
+	fwsvc "github.com/wiidz/gin_template/internal/domain/shared/firewall/service"
 	usersvc "github.com/wiidz/gin_template/internal/domain/shared/user/service"
 
 	idmng "github.com/wiidz/goutil/mngs/identityMng"
 )
 
-func BuildEngine() *gin.Engine {
-	e := gin.New()
-
+// Routes registers the console port's handlers.
+func Routes(e *gin.Engine) {
 	// user console 业务路由（使用 Manager 实例）
 	mng, _ := idmng.NewMng(&idmng.Config{DefaultDevice: "client"})
 	// repos.Setup 应在 server/main 处传入
 	uRepo := repos.User.Repo
-	uSvc := usersvc.New(uRepo, mng)
+	uSvc := usersvc.New(uRepo, usersvc.NewTokenStore(repos.User.DB), mng)
 	uConsole := userhandler.NewConsoleHandler(uSvc)
 
 	e.GET("/health", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+	// Prometheus scrape endpoint; served on the console port only, to the
+	// scrapers of metrics.allow / metrics.bearerToken
+	e.GET("/metrics", middleware.MetricsAccess(), gin.WrapH(metrics.Handler()))
+
+	// runtime log levels; reset by the next config reload of log.level(s)
+	adm := e.Group("/admin")
+	adm.Use(sagin.CheckLogin(), sagin.CheckRole("admin"))
+	adm.GET("/log-level", admin.GetLogLevel)
+	adm.PUT("/log-level", admin.PutLogLevel)
+	// rate limit policies, recent buckets and top offenders of this replica
+	adm.GET("/rate-limits", admin.GetRateLimits)
+	// firewall rules stored in the database, with an audit trail
+	fwConsole := fwhandler.NewConsoleHandler(fwsvc.New(repos.Firewall.DB, app.Names()))
+	adm.GET("/firewall/rules", fwConsole.List)
+	adm.POST("/firewall/rules", fwConsole.Add)
+	adm.DELETE("/firewall/rules/:id", fwConsole.Remove)
+	adm.GET("/firewall/audit", fwConsole.Audit)
+	adm.GET("/firewall/bans", fwConsole.Bans)
+	adm.DELETE("/firewall/bans/:ip", fwConsole.Unban)
 
 	v1 := e.Group("/api/v1")
 	{
@@ -33,8 +57,11 @@ func BuildEngine() *gin.Engine {
 
 		protected.GET("/users", uConsole.List)
 		protected.GET("/users/:id", uConsole.Get)
+		protected.POST("/users", uConsole.Create)
+		protected.PATCH("/users/:id", uConsole.Update)
+		protected.DELETE("/users/:id", uConsole.Delete)
+		protected.POST("/users/:id/reset-password", uConsole.ResetPassword)
 
 		// 这里不再挂载 IAM Subject（已移除复杂 identityMng）
 	}
-	return e
 }
@@ -1,26 +1,188 @@
 package user
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/wiidz/goutil/structs/networkStruct"
 
+	"github.com/wiidz/gin_template/internal/common/apperr"
+	"github.com/wiidz/gin_template/internal/common/binding"
+	"github.com/wiidz/gin_template/internal/common/response"
+	"github.com/wiidz/gin_template/internal/domain/shared/user/dto"
+	"github.com/wiidz/gin_template/internal/domain/shared/user/model"
 	usersvc "github.com/wiidz/gin_template/internal/domain/shared/user/service"
 )
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
 type ConsoleHandler struct{ S *usersvc.Service }
 
 func NewConsoleHandler(s *usersvc.Service) *ConsoleHandler { return &ConsoleHandler{S: s} }
 
-// TODO: implement pagination and filtering when repo supports it
+// List serves GET /users?page=&page_size=&sort=-created_at,login_id
+// &login_id=<prefix>&nickname=<contains>&created_from=&created_to=
 func (h *ConsoleHandler) List(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"items": []interface{}{}, "total": 0})
+	q, err := parseListQuery(c)
+	if err != nil {
+		response.Fail(c, apperr.BadRequest.WithMessage(err.Error()))
+		return
+	}
+	users, total, err := h.S.List(c.Request.Context(), q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	items := make([]dto.UserView, 0, len(users))
+	for _, u := range users {
+		items = append(items, toView(u))
+	}
+	response.OK(c, response.Page[dto.UserView]{Total: total, Page: q.Page, PageSize: q.PageSize, Items: items})
 }
 
 func (h *ConsoleHandler) Get(c *gin.Context) {
-	idStr := c.Param("id")
-	_, _ = strconv.ParseUint(idStr, 10, 64)
-	// TODO: call service when implemented
-	c.JSON(http.StatusOK, gin.H{"id": idStr})
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	u, err := h.S.Get(c.Request.Context(), id)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.OK(c, toView(u))
+}
+
+func (h *ConsoleHandler) Create(c *gin.Context) {
+	var req dto.CreateUserRequest
+	if !binding.Bind(c, &req, networkStruct.BodyJson) {
+		return
+	}
+	u, err := h.S.Create(c.Request.Context(), req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Respond(c, http.StatusCreated, response.SuccessResponse[dto.UserView]{Msg: "ok", Data: toView(u)})
+}
+
+func (h *ConsoleHandler) Update(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	var req dto.UpdateUserRequest
+	if !binding.Bind(c, &req, networkStruct.BodyJson) {
+		return
+	}
+	u, err := h.S.Update(c.Request.Context(), id, req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.OK(c, toView(u))
+}
+
+func (h *ConsoleHandler) ResetPassword(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	var req dto.ResetPasswordRequest
+	if !binding.Bind(c, &req, networkStruct.BodyJson) {
+		return
+	}
+	if err := h.S.ResetPassword(c.Request.Context(), id, req.Password); err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.OK(c, gin.H{"ok": true})
+}
+
+func (h *ConsoleHandler) Delete(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	if err := h.S.Delete(c.Request.Context(), id); err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.OK(c, gin.H{"ok": true})
+}
+
+func parseID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		response.Fail(c, apperr.BadRequest.WithMessage("invalid id"))
+		return 0, false
+	}
+	return id, true
+}
+
+func parseListQuery(c *gin.Context) (dto.ListUsersQuery, error) {
+	q := dto.ListUsersQuery{
+		Page:     1,
+		PageSize: defaultPageSize,
+		Sort:     c.Query("sort"),
+		LoginID:  c.Query("login_id"),
+		Nickname: c.Query("nickname"),
+	}
+	if v := c.Query("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return q, errors.New("page must be a positive integer")
+		}
+		q.Page = n
+	}
+	if v := c.Query("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return q, errors.New("page_size must be between 1 and 100")
+		}
+		q.PageSize = n
+	}
+	if v := c.Query("created_from"); v != "" {
+		t, _, err := parseTime(v)
+		if err != nil {
+			return q, errors.New("created_from must be RFC3339 or YYYY-MM-DD")
+		}
+		q.CreatedFrom = &t
+	}
+	if v := c.Query("created_to"); v != "" {
+		t, dateOnly, err := parseTime(v)
+		if err != nil {
+			return q, errors.New("created_to must be RFC3339 or YYYY-MM-DD")
+		}
+		if dateOnly {
+			// a bare date covers the whole day
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		q.CreatedTo = &t
+	}
+	return q, nil
+}
+
+func parseTime(v string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	t, err = time.ParseInLocation(time.DateOnly, v, time.Local)
+	return t, true, err
+}
+
+func toView(u *model.User) dto.UserView {
+	return dto.UserView{
+		ID:        u.ID,
+		LoginID:   u.LoginID,
+		Nickname:  u.Nickname,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
 }
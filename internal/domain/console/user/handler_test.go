@@ -0,0 +1,90 @@
+package user
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/wiidz/gin_template/internal/domain/shared/user/dto"
+)
+
+func TestParseListQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	at := func(s string) *time.Time {
+		v, err := time.ParseInLocation(time.RFC3339Nano, s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &v
+	}
+	tests := []struct {
+		name    string
+		query   string
+		want    dto.ListUsersQuery
+		wantErr bool
+	}{
+		{name: "defaults", want: dto.ListUsersQuery{Page: 1, PageSize: defaultPageSize}},
+		{
+			name:  "all parameters",
+			query: "page=3&page_size=50&sort=-created_at,login_id&login_id=al&nickname=bo",
+			want:  dto.ListUsersQuery{Page: 3, PageSize: 50, Sort: "-created_at,login_id", LoginID: "al", Nickname: "bo"},
+		},
+		{
+			name:  "rfc3339 range",
+			query: "created_from=2026-01-02T03:04:05Z&created_to=2026-01-03T00:00:00%2B08:00",
+			want: dto.ListUsersQuery{
+				Page: 1, PageSize: defaultPageSize,
+				CreatedFrom: at("2026-01-02T03:04:05Z"), CreatedTo: at("2026-01-03T00:00:00+08:00"),
+			},
+		},
+		{
+			name:  "a bare created_to date covers the day",
+			query: "created_from=2026-01-02&created_to=2026-01-02",
+			want: dto.ListUsersQuery{
+				Page: 1, PageSize: defaultPageSize,
+				CreatedFrom: at("2026-01-02T00:00:00" + localOffset(t)), CreatedTo: at("2026-01-02T23:59:59.999999999" + localOffset(t)),
+			},
+		},
+		{name: "page zero", query: "page=0", wantErr: true},
+		{name: "page not a number", query: "page=x", wantErr: true},
+		{name: "page_size too large", query: "page_size=101", wantErr: true},
+		{name: "bad created_from", query: "created_from=yesterday", wantErr: true},
+		{name: "bad created_to", query: "created_to=2026-13-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil)
+			got, err := parseListQuery(c)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("query %q accepted", tt.query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Page != tt.want.Page || got.PageSize != tt.want.PageSize || got.Sort != tt.want.Sort ||
+				got.LoginID != tt.want.LoginID || got.Nickname != tt.want.Nickname ||
+				!sameTime(got.CreatedFrom, tt.want.CreatedFrom) || !sameTime(got.CreatedTo, tt.want.CreatedTo) {
+				t.Fatalf("parseListQuery = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func localOffset(t *testing.T) string {
+	t.Helper()
+	return time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local).Format("Z07:00")
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/wiidz/goutil/structs/networkStruct"
+)
+
+// AddRuleRequest adds a deny rule, or an allow rule that restricts Port to
+// the allowlisted ranges.
+type AddRuleRequest struct {
+	networkStruct.Params `swaggerignore:"true"`
+
+	Action string `json:"action" belong:"value" validate:"required,oneof=deny allow"`
+	CIDR   string `json:"cidr" belong:"value" validate:"required"`                 // IP or CIDR block, IPv4 or IPv6
+	Port   string `json:"port" belong:"value" validate:"required_if=Action allow"` // empty = every port (deny only)
+	Reason string `json:"reason" belong:"value" validate:"max=256"`
+	TTL    string `json:"ttl" belong:"value"` // e.g. 24h; empty = never expires
+}
+
+// Actor is who made a change, for the audit trail.
+type Actor struct {
+	LoginID string
+	IP      string
+	Port    string // the port the change was made through
+}
+
+// ListAuditQuery pages through the audit trail, newest first.
+type ListAuditQuery struct {
+	Page     int
+	PageSize int
+	RuleID   uint64 // 0 = every rule
+}
+
+type RuleView struct {
+	ID        uint64     `json:"id"`
+	Action    string     `json:"action"`
+	CIDR      string     `json:"cidr"`
+	Port      string     `json:"port,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type AuditView struct {
+	ID        uint64     `json:"id"`
+	RuleID    uint64     `json:"rule_id"`
+	Op        string     `json:"op"`
+	Action    string     `json:"action"`
+	CIDR      string     `json:"cidr"`
+	Port      string     `json:"port,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Actor     string     `json:"actor,omitempty"`
+	ActorIP   string     `json:"actor_ip,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+// FirewallRuleEntity is a deny or allow rule managed from the console.
+type FirewallRuleEntity struct {
+	ID        uint64     `gorm:"primaryKey;autoIncrement"`
+	Action    string     `gorm:"size:8;not null"`
+	CIDR      string     `gorm:"column:cidr;size:64;not null;index"`
+	Port      string     `gorm:"size:64;not null;default:''"`
+	Reason    string     `gorm:"size:256"`
+	ExpiresAt *time.Time `gorm:"index"`
+	CreatedBy string     `gorm:"size:128"`
+	CreatedAt time.Time
+}
+
+// FirewallAuditEntity records every change to the rules. It copies the rule
+// so the trail survives the rule's removal.
+type FirewallAuditEntity struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	RuleID    uint64 `gorm:"index;not null"`
+	Op        string `gorm:"size:16;not null"` // add, remove
+	Action    string `gorm:"size:8;not null"`
+	CIDR      string `gorm:"column:cidr;size:64;not null"`
+	Port      string `gorm:"size:64;not null;default:''"`
+	Reason    string `gorm:"size:256"`
+	ExpiresAt *time.Time
+	Actor     string    `gorm:"size:128"`
+	ActorIP   string    `gorm:"size:64"`
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+)
+
+// Error codes of the firewall domain.
+var (
+	CodeInvalidCIDR  = apperr.Register("firewall", "invalid_cidr", http.StatusBadRequest, "invalid IP or CIDR block")
+	CodeInvalidTTL   = apperr.Register("firewall", "invalid_ttl", http.StatusBadRequest, "ttl must be a positive duration such as 24h")
+	CodeUnknownPort  = apperr.Register("firewall", "unknown_port", http.StatusBadRequest, "unknown port")
+	CodeAllowNoPort  = apperr.Register("firewall", "allow_without_port", http.StatusBadRequest, "allow rules need a port")
+	CodeLockout      = apperr.Register("firewall", "lockout", http.StatusConflict, "the rule would block your own IP on this port")
+	CodeRuleNotFound = apperr.Register("firewall", "rule_not_found", http.StatusNotFound, "firewall rule not found")
+)
+
+func init() {
+	apperr.Map(ErrInvalidCIDR, CodeInvalidCIDR)
+	apperr.Map(ErrInvalidTTL, CodeInvalidTTL)
+	apperr.Map(ErrUnknownPort, CodeUnknownPort)
+	apperr.Map(ErrAllowNoPort, CodeAllowNoPort)
+	apperr.Map(ErrLockout, CodeLockout)
+	apperr.Map(ErrRuleNotFound, CodeRuleNotFound)
+}
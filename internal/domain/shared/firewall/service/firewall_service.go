@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"time"
+
+	repoMng "github.com/wiidz/goutil/mngs/repoMng"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/wiidz/gin_template/internal/common/firewall"
+	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/tracing"
+	"github.com/wiidz/gin_template/internal/domain/shared/firewall/dto"
+	"github.com/wiidz/gin_template/internal/domain/shared/firewall/entity"
+)
+
+var (
+	ErrInvalidCIDR  = errors.New("invalid IP or CIDR block")
+	ErrInvalidTTL   = errors.New("invalid ttl")
+	ErrUnknownPort  = errors.New("unknown port")
+	ErrAllowNoPort  = errors.New("allow rule without a port")
+	ErrLockout      = errors.New("rule would lock out the caller")
+	ErrRuleNotFound = errors.New("firewall rule not found")
+)
+
+var tracer = tracing.Tracer("firewallsvc")
+
+// Service stores the database source of the firewall. Every change is
+// written together with its audit record and applied to this replica at
+// once; other replicas pick it up on their next firewall.Sync.
+type Service struct {
+	db    *gorm.DB
+	ports []string // names of the configured ports
+}
+
+func New(db *gorm.DB, ports []string) *Service {
+	return &Service{db: db, ports: ports}
+}
+
+// List returns the rules that have not expired, newest first.
+func (s *Service) List(ctx context.Context) ([]*entity.FirewallRuleEntity, error) {
+	rows, _, err := repoMng.RepoOf[entity.FirewallRuleEntity](s.db).List(ctx,
+		repoMng.WithScopes(notExpired(time.Now())), repoMng.WithOrder("id DESC"))
+	return rows, err
+}
+
+// Load returns the active rules for firewall.Sync.
+func (s *Service) Load(ctx context.Context) ([]firewall.Rule, error) {
+	rows, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]firewall.Rule, 0, len(rows))
+	for _, r := range rows {
+		p, err := firewall.ParsePrefix(r.CIDR)
+		if err != nil {
+			logger.FromContext(ctx).Named("firewall").Warn("firewall_rule_skipped", zap.Uint64("rule_id", r.ID), zap.Error(err))
+			continue
+		}
+		rule := firewall.Rule{ID: r.ID, Action: r.Action, Prefix: p, Port: r.Port, Reason: r.Reason}
+		if r.ExpiresAt != nil {
+			rule.ExpiresAt = *r.ExpiresAt
+		}
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+func (s *Service) Add(ctx context.Context, req dto.AddRuleRequest, actor dto.Actor) (_ *entity.FirewallRuleEntity, err error) {
+	ctx, span := tracer.Start(ctx, "firewallsvc.Add")
+	defer func() { tracing.End(span, err) }()
+
+	if s.db == nil {
+		return nil, gorm.ErrInvalidDB
+	}
+	p, err := firewall.ParsePrefix(req.CIDR)
+	if err != nil {
+		return nil, ErrInvalidCIDR
+	}
+	if req.Port != "" && !slices.Contains(s.ports, req.Port) {
+		return nil, ErrUnknownPort
+	}
+	switch req.Action {
+	case firewall.Allow:
+		// an allow rule for every port would close all of them but to p
+		if req.Port == "" {
+			return nil, ErrAllowNoPort
+		}
+		if req.Port == actor.Port && !coversIP(p, actor.IP) && !firewall.Allowlisted(req.Port, actor.IP) {
+			return nil, ErrLockout
+		}
+	case firewall.Deny:
+		// deny beats every allow rule, so nothing could let the caller back in
+		if (req.Port == "" || req.Port == actor.Port) && coversIP(p, actor.IP) {
+			return nil, ErrLockout
+		}
+	}
+	rule := &entity.FirewallRuleEntity{
+		Action:    req.Action,
+		CIDR:      p.String(),
+		Port:      req.Port,
+		Reason:    req.Reason,
+		CreatedBy: actor.LoginID,
+	}
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, ErrInvalidTTL
+		}
+		at := time.Now().Add(ttl)
+		rule.ExpiresAt = &at
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := repoMng.RepoOf[entity.FirewallRuleEntity](tx).Create(ctx, rule); err != nil {
+			return err
+		}
+		return repoMng.RepoOf[entity.FirewallAuditEntity](tx).Create(ctx, auditOf("add", rule, actor))
+	})
+	if err != nil {
+		return nil, err
+	}
+	firewall.Refresh(firewall.SourceDatabase)
+	logger.FromContext(ctx).Named("firewall").Info("firewall_rule_added", ruleFields(rule)...)
+	return rule, nil
+}
+
+func (s *Service) Remove(ctx context.Context, id uint64, actor dto.Actor) (err error) {
+	ctx, span := tracer.Start(ctx, "firewallsvc.Remove")
+	defer func() { tracing.End(span, err) }()
+
+	if s.db == nil {
+		return gorm.ErrInvalidDB
+	}
+	var rule *entity.FirewallRuleEntity
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rules := repoMng.RepoOf[entity.FirewallRuleEntity](tx)
+		var err error
+		if rule, err = rules.GetByID(ctx, id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRuleNotFound
+			}
+			return err
+		}
+		if err := rules.Delete(ctx, repoMng.WithEq("id", id)); err != nil {
+			return err
+		}
+		return repoMng.RepoOf[entity.FirewallAuditEntity](tx).Create(ctx, auditOf("remove", rule, actor))
+	})
+	if err != nil {
+		return err
+	}
+	firewall.Refresh(firewall.SourceDatabase)
+	logger.FromContext(ctx).Named("firewall").Info("firewall_rule_removed", ruleFields(rule)...)
+	return nil
+}
+
+// Audit pages through the audit trail, newest first.
+func (s *Service) Audit(ctx context.Context, q dto.ListAuditQuery) ([]*entity.FirewallAuditEntity, int64, error) {
+	opts := []repoMng.Selector{repoMng.WithOrder("id DESC"), repoMng.WithPage(q.Page, q.PageSize)}
+	if q.RuleID != 0 {
+		opts = append(opts, repoMng.WithEq("rule_id", q.RuleID))
+	}
+	return repoMng.RepoOf[entity.FirewallAuditEntity](s.db).List(ctx, opts...)
+}
+
+func notExpired(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("expires_at IS NULL OR expires_at > ?", now)
+	}
+}
+
+func auditOf(op string, r *entity.FirewallRuleEntity, actor dto.Actor) *entity.FirewallAuditEntity {
+	return &entity.FirewallAuditEntity{
+		RuleID:    r.ID,
+		Op:        op,
+		Action:    r.Action,
+		CIDR:      r.CIDR,
+		Port:      r.Port,
+		Reason:    r.Reason,
+		ExpiresAt: r.ExpiresAt,
+		Actor:     actor.LoginID,
+		ActorIP:   actor.IP,
+	}
+}
+
+func ruleFields(r *entity.FirewallRuleEntity) []zap.Field {
+	fields := []zap.Field{
+		zap.Uint64("rule_id", r.ID),
+		zap.String("action", r.Action),
+		zap.String("cidr", r.CIDR),
+		zap.String("port", r.Port),
+		zap.String("reason", r.Reason),
+	}
+	if r.ExpiresAt != nil {
+		fields = append(fields, zap.Time("expires_at", *r.ExpiresAt))
+	}
+	return fields
+}
+
+func coversIP(p netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && p.Contains(addr.Unmap())
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/wiidz/gin_template/internal/common/firewall"
+	"github.com/wiidz/gin_template/internal/domain/shared/firewall/dto"
+	"github.com/wiidz/gin_template/internal/domain/shared/firewall/entity"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1) // every connection would get its own memory database
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err := db.AutoMigrate(&entity.FirewallRuleEntity{}, &entity.FirewallAuditEntity{}); err != nil {
+		t.Fatal(err)
+	}
+	return New(db, []string{"client", "console"})
+}
+
+func TestAddRuleLockout(t *testing.T) {
+	admin := dto.Actor{LoginID: "admin", IP: "203.0.113.7", Port: "console"}
+	tests := []struct {
+		name      string
+		req       dto.AddRuleRequest
+		actor     dto.Actor
+		allowlist []firewall.Rule // rules already in force
+		wantErr   error
+	}{
+		{
+			name:    "allow without a port",
+			req:     dto.AddRuleRequest{Action: firewall.Allow, CIDR: "10.0.0.0/8"},
+			actor:   admin,
+			wantErr: ErrAllowNoPort,
+		},
+		{
+			name:    "deny without a port",
+			req:     dto.AddRuleRequest{Action: firewall.Deny, CIDR: "198.51.100.0/24"},
+			actor:   admin,
+			wantErr: nil,
+		},
+		{
+			name:    "console allow missing the caller",
+			req:     dto.AddRuleRequest{Action: firewall.Allow, CIDR: "10.0.0.0/8", Port: "console"},
+			actor:   admin,
+			wantErr: ErrLockout,
+		},
+		{
+			name:    "console allow covering the caller",
+			req:     dto.AddRuleRequest{Action: firewall.Allow, CIDR: "203.0.113.0/24", Port: "console"},
+			actor:   admin,
+			wantErr: nil,
+		},
+		{
+			name:    "console allow covering an IPv4-mapped caller",
+			req:     dto.AddRuleRequest{Action: firewall.Allow, CIDR: "203.0.113.0/24", Port: "console"},
+			actor:   dto.Actor{LoginID: "admin", IP: "::ffff:203.0.113.7", Port: "console"},
+			wantErr: nil,
+		},
+		{
+			name:      "caller already allowlisted",
+			req:       dto.AddRuleRequest{Action: firewall.Allow, CIDR: "10.0.0.0/8", Port: "console"},
+			actor:     admin,
+			allowlist: []firewall.Rule{{Action: firewall.Allow, Prefix: netip.MustParsePrefix("203.0.113.7/32"), Port: "console"}},
+			wantErr:   nil,
+		},
+		{
+			name:    "allow on another port than the caller's",
+			req:     dto.AddRuleRequest{Action: firewall.Allow, CIDR: "10.0.0.0/8", Port: "client"},
+			actor:   admin,
+			wantErr: nil,
+		},
+		{
+			name:    "deny everything",
+			req:     dto.AddRuleRequest{Action: firewall.Deny, CIDR: "0.0.0.0/0"},
+			actor:   admin,
+			wantErr: ErrLockout,
+		},
+		{
+			name:    "deny the caller's block on the caller's port",
+			req:     dto.AddRuleRequest{Action: firewall.Deny, CIDR: "203.0.113.0/24", Port: "console"},
+			actor:   admin,
+			wantErr: ErrLockout,
+		},
+		{
+			name:      "deny the caller despite an allow rule",
+			req:       dto.AddRuleRequest{Action: firewall.Deny, CIDR: "203.0.113.7", Port: "console"},
+			actor:     dto.Actor{LoginID: "admin", IP: "::ffff:203.0.113.7", Port: "console"},
+			allowlist: []firewall.Rule{{Action: firewall.Allow, Prefix: netip.MustParsePrefix("203.0.113.7/32"), Port: "console"}},
+			wantErr:   ErrLockout,
+		},
+		{
+			name:    "deny the caller's block on another port",
+			req:     dto.AddRuleRequest{Action: firewall.Deny, CIDR: "203.0.113.0/24", Port: "client"},
+			actor:   admin,
+			wantErr: nil,
+		},
+		{
+			name:    "unknown port",
+			req:     dto.AddRuleRequest{Action: firewall.Allow, CIDR: "10.0.0.0/8", Port: "admin"},
+			actor:   admin,
+			wantErr: ErrUnknownPort,
+		},
+	}
+	t.Cleanup(func() { firewall.SetRules(firewall.SourceConfig, nil) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firewall.SetRules(firewall.SourceConfig, tt.allowlist)
+			s := newTestService(t)
+			_, err := s.Add(context.Background(), tt.req, tt.actor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			rows, err := s.List(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored := len(rows) == 1; stored != (tt.wantErr == nil) {
+				t.Fatalf("%d rules stored", len(rows))
+			}
+		})
+	}
+}
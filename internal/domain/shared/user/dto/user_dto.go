@@ -1,16 +1,68 @@
 package dto
 
-import "github.com/wiidz/goutil/structs/networkStruct"
+import (
+	"time"
+
+	"github.com/wiidz/goutil/structs/networkStruct"
+)
 
 type LoginRequest struct {
 	networkStruct.Params `swaggerignore:"true"`
 
 	LoginID  string `json:"login_id" belong:"value" validate:"required"`
 	Password string `json:"password" belong:"value" validate:"required"`
-	Device   string `json:"device" belong:"value" default:"client"`
+	Device   string `json:"device" belong:"value" default:"client" validate:"max=64"`
+}
+
+type RefreshRequest struct {
+	networkStruct.Params `swaggerignore:"true"`
+
+	RefreshToken string `json:"refresh_token" belong:"value" validate:"required"`
 }
 
 type TokenPair struct {
-	AccessToken  string `json:"accessToken"`
-	RefreshToken string `json:"refreshToken"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type CreateUserRequest struct {
+	networkStruct.Params `swaggerignore:"true"`
+
+	LoginID  string `json:"login_id" belong:"value" validate:"required,max=128"`
+	Password string `json:"password" belong:"value" validate:"required,min=8,max=72"`
+	Nickname string `json:"nickname" belong:"value" validate:"max=128"`
+}
+
+// UpdateUserRequest patches only the fields present in the body.
+type UpdateUserRequest struct {
+	networkStruct.Params `swaggerignore:"true"`
+
+	LoginID  *string `json:"login_id" belong:"value" validate:"omitempty,min=1,max=128"`
+	Nickname *string `json:"nickname" belong:"value" validate:"omitempty,max=128"`
+}
+
+type ResetPasswordRequest struct {
+	networkStruct.Params `swaggerignore:"true"`
+
+	Password string `json:"password" belong:"value" validate:"required,min=8,max=72"`
+}
+
+// ListUsersQuery carries console list parameters; zero values mean "no filter".
+type ListUsersQuery struct {
+	Page        int
+	PageSize    int
+	Sort        string // comma separated columns, "-" prefix for descending, e.g. -created_at,login_id
+	LoginID     string // prefix match
+	Nickname    string // substring match
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// UserView is the console-facing user representation; it never carries the password hash.
+type UserView struct {
+	ID        uint64    `json:"id"`
+	LoginID   string    `json:"login_id"`
+	Nickname  string    `json:"nickname"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// RefreshTokenEntity is an issued refresh token, stored as its SHA-256. A
+// family is every token issued to one login ID on one device; rotated tokens
+// stay until they expire so that replaying one can be detected.
+// AccessToken is the token issued with it, logged out on rotation.
+type RefreshTokenEntity struct {
+	TokenHash   string     `gorm:"primaryKey;size:64"`
+	LoginID     string     `gorm:"size:128;not null;index:idx_refresh_token_family,priority:1"`
+	Device      string     `gorm:"size:64;not null;index:idx_refresh_token_family,priority:2"`
+	AccessToken string     `gorm:"type:text;not null;index"`
+	RotatedAt   *time.Time // nil until redeemed
+	ExpiresAt   time.Time  `gorm:"not null;index"`
+	CreatedAt   time.Time
+}
@@ -10,7 +10,3 @@ type UserEntity struct {
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
-
-func EntitiesForMigrate() []interface{} {
-	return []interface{}{&UserEntity{}}
-}
@@ -1,8 +1,12 @@
 package model
 
+import "time"
+
 type User struct {
 	ID           uint64
 	LoginID      string
 	Nickname     string
 	PasswordHash string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
 }
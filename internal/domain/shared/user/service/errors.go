@@ -0,0 +1,27 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+)
+
+// Error codes of the user domain. The sentinel errors in user_service.go map to
+// them, so handlers can pass service errors straight to response.Fail.
+var (
+	CodeInvalidCredentials  = apperr.Register("user", "invalid_credentials", http.StatusUnauthorized, "invalid login id or password")
+	CodeInvalidRefreshToken = apperr.Register("user", "invalid_refresh_token", http.StatusUnauthorized, "invalid or expired refresh token")
+	CodeRefreshTokenReused  = apperr.Register("user", "refresh_token_reused", http.StatusUnauthorized, "refresh token already used; please log in again")
+	CodeInvalidSort         = apperr.Register("user", "invalid_sort", http.StatusBadRequest, "invalid sort field")
+	CodeNotFound            = apperr.Register("user", "not_found", http.StatusNotFound, "user not found")
+	CodeLoginIDTaken        = apperr.Register("user", "login_id_taken", http.StatusConflict, "login id already exists")
+)
+
+func init() {
+	apperr.Map(ErrInvalidCredentials, CodeInvalidCredentials)
+	apperr.Map(ErrInvalidRefreshToken, CodeInvalidRefreshToken)
+	apperr.Map(ErrRefreshTokenReused, CodeRefreshTokenReused)
+	apperr.Map(ErrInvalidSort, CodeInvalidSort)
+	apperr.Map(ErrUserNotFound, CodeNotFound)
+	apperr.Map(ErrLoginIDTaken, CodeLoginIDTaken)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/wiidz/gin_template/internal/common/apperr"
+)
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantCode   string
+		wantStatus int
+	}{
+		{ErrInvalidCredentials, "user.invalid_credentials", http.StatusUnauthorized},
+		{ErrInvalidRefreshToken, "user.invalid_refresh_token", http.StatusUnauthorized},
+		{ErrRefreshTokenReused, "user.refresh_token_reused", http.StatusUnauthorized},
+		{ErrInvalidSort, "user.invalid_sort", http.StatusBadRequest},
+		{ErrUserNotFound, "user.not_found", http.StatusNotFound},
+		{ErrLoginIDTaken, "user.login_id_taken", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantCode, func(t *testing.T) {
+			// services may wrap their sentinels
+			e := apperr.From(fmt.Errorf("user 7: %w", tt.err))
+			if e.Code != tt.wantCode || e.Status != tt.wantStatus {
+				t.Fatalf("From(%v) = %s %d, want %s %d", tt.err, e.Code, e.Status, tt.wantCode, tt.wantStatus)
+			}
+		})
+	}
+}
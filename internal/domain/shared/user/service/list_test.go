@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/wiidz/goutil/mngs/repoMng"
+
+	"github.com/wiidz/gin_template/internal/domain/shared/user/dto"
+	"github.com/wiidz/gin_template/internal/domain/shared/user/entity"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		sort    string
+		want    string
+		wantErr bool
+	}{
+		{"", "id DESC", false},
+		{" , ", "id DESC", false},
+		{"-created_at,login_id", "created_at DESC, login_id ASC", false},
+		{"+nickname, -id", "nickname ASC, id DESC", false},
+		{"password_hash", "", true},
+		{"login_id;drop table users", "", true},
+		{"--id", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			got, err := parseSort(tt.sort)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSort) {
+					t.Fatalf("err = %v, want ErrInvalidSort", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("parseSort = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceList(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	s := New(repoMng.RepoOf[entity.UserEntity](db), NewTokenStore(db), nil)
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 12, 0, 0, 0, time.UTC) }
+	for i, u := range []struct{ loginID, nickname string }{
+		{"alice", "Alice 100%"},
+		{"alan", "al_an"},
+		{"bob", "alan's friend"},
+		{"a_b", "underscore"},
+	} {
+		created, err := s.Create(ctx, dto.CreateUserRequest{LoginID: u.loginID, Password: "password1", Nickname: u.nickname})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Model(&entity.UserEntity{}).Where("id = ?", created.ID).Update("created_at", day(i+1)).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	from, to := day(2), day(3)
+
+	tests := []struct {
+		name      string
+		q         dto.ListUsersQuery
+		want      []string
+		wantTotal int64
+	}{
+		{"default order", dto.ListUsersQuery{}, []string{"a_b", "bob", "alan", "alice"}, 4},
+		{"sort", dto.ListUsersQuery{Sort: "login_id"}, []string{"a_b", "alan", "alice", "bob"}, 4},
+		{"page", dto.ListUsersQuery{Sort: "login_id", Page: 2, PageSize: 3}, []string{"bob"}, 4},
+		{"login id prefix", dto.ListUsersQuery{LoginID: "al", Sort: "login_id"}, []string{"alan", "alice"}, 2},
+		{"prefix wildcard is literal", dto.ListUsersQuery{LoginID: "a_"}, []string{"a_b"}, 1},
+		{"nickname contains", dto.ListUsersQuery{Nickname: "alan", Sort: "id"}, []string{"bob"}, 1},
+		{"nickname wildcards are literal", dto.ListUsersQuery{Nickname: "0%"}, []string{"alice"}, 1},
+		{"nickname underscore is literal", dto.ListUsersQuery{Nickname: "l_a"}, []string{"alan"}, 1},
+		{"created range", dto.ListUsersQuery{CreatedFrom: &from, CreatedTo: &to, Sort: "created_at"}, []string{"alan", "bob"}, 2},
+		{"created from", dto.ListUsersQuery{CreatedFrom: &to, Sort: "-created_at"}, []string{"a_b", "bob"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := s.List(ctx, tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(users))
+			for _, u := range users {
+				got = append(got, u.LoginID)
+			}
+			if !slices.Equal(got, tt.want) || total != tt.wantTotal {
+				t.Fatalf("List = %q (total %d), want %q (total %d)", got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+
+	if _, _, err := s.List(ctx, dto.ListUsersQuery{Sort: "password_hash"}); !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("err = %v, want ErrInvalidSort", err)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/wiidz/gin_template/internal/domain/shared/user/entity"
+)
+
+// refreshTTL matches sa-token's default refresh token lifetime.
+const refreshTTL = 30 * 24 * time.Hour
+
+type refreshRecord struct {
+	loginID     string
+	device      string
+	accessToken string
+}
+
+// TokenStore keeps refresh token families in the database, so they survive
+// restarts and every replica and port sees the same state. Refresh tokens
+// are stored hashed; access tokens are indexed to find their family on
+// logout.
+type TokenStore struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewTokenStore(db *gorm.DB) *TokenStore {
+	return &TokenStore{db: db, now: time.Now}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *TokenStore) add(ctx context.Context, loginID, device, accessToken, refreshToken string) error {
+	now := t.now()
+	db := t.db.WithContext(ctx)
+	// expired tokens can no longer be replayed, so nothing is lost
+	if err := db.Where("expires_at <= ?", now).Delete(&entity.RefreshTokenEntity{}).Error; err != nil {
+		return err
+	}
+	return db.Create(&entity.RefreshTokenEntity{
+		TokenHash:   hashToken(refreshToken),
+		LoginID:     loginID,
+		Device:      device,
+		AccessToken: accessToken,
+		ExpiresAt:   now.Add(refreshTTL),
+		CreatedAt:   now,
+	}).Error
+}
+
+// rotate marks refreshToken as used. reused is true when the token had
+// already been rotated, which means it leaked and the family must be
+// revoked. Of two concurrent rotations of one token, one sees reused.
+func (t *TokenStore) rotate(ctx context.Context, refreshToken string) (rec refreshRecord, reused bool, err error) {
+	now := t.now()
+	hash := hashToken(refreshToken)
+	db := t.db.WithContext(ctx)
+	res := db.Model(&entity.RefreshTokenEntity{}).
+		Where("token_hash = ? AND rotated_at IS NULL AND expires_at > ?", hash, now).
+		Update("rotated_at", now)
+	if res.Error != nil {
+		return refreshRecord{}, false, res.Error
+	}
+	var row entity.RefreshTokenEntity
+	if err := db.Where("token_hash = ? AND expires_at > ?", hash, now).Take(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return refreshRecord{}, false, ErrInvalidRefreshToken
+		}
+		return refreshRecord{}, false, err
+	}
+	return refreshRecord{loginID: row.LoginID, device: row.Device, accessToken: row.AccessToken}, res.RowsAffected == 0, nil
+}
+
+// familyOfAccessToken returns the family an active access token belongs to.
+func (t *TokenStore) familyOfAccessToken(ctx context.Context, accessToken string) (loginID, device string, ok bool, err error) {
+	var row entity.RefreshTokenEntity
+	err = t.db.WithContext(ctx).
+		Where("access_token = ? AND rotated_at IS NULL AND expires_at > ?", accessToken, t.now()).
+		Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	return row.LoginID, row.Device, true, nil
+}
+
+// revoke drops every refresh token of the family and returns the access
+// tokens issued with them so the caller can log them out.
+func (t *TokenStore) revoke(ctx context.Context, loginID, device string) ([]string, error) {
+	var accessTokens []string
+	err := t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Model(&entity.RefreshTokenEntity{}).Where("login_id = ? AND device = ?", loginID, device)
+		if err := q.Pluck("access_token", &accessTokens).Error; err != nil {
+			return err
+		}
+		return tx.Where("login_id = ? AND device = ?", loginID, device).Delete(&entity.RefreshTokenEntity{}).Error
+	})
+	return accessTokens, err
+}
+
+// devices lists the devices loginID holds refresh tokens for.
+func (t *TokenStore) devices(ctx context.Context, loginID string) ([]string, error) {
+	var devices []string
+	err := t.db.WithContext(ctx).Model(&entity.RefreshTokenEntity{}).
+		Where("login_id = ?", loginID).Distinct().Pluck("device", &devices).Error
+	return devices, err
+}
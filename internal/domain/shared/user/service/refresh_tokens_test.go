@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/wiidz/gin_template/internal/domain/shared/user/entity"
+)
+
+// newTestDB returns an in-memory SQLite database with the user tables.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1) // every connection would get its own memory database
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err := db.AutoMigrate(&entity.UserEntity{}, &entity.RefreshTokenEntity{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestStore returns a TokenStore over newTestDB whose clock the test
+// controls.
+func newTestStore(t *testing.T) (*TokenStore, *time.Time) {
+	t.Helper()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	store := NewTokenStore(newTestDB(t))
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestTokenStoreRotation(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	if err := store.add(ctx, "alice", "ios", "access-1", "refresh-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, reused, err := store.rotate(ctx, "refresh-1")
+	if err != nil || reused {
+		t.Fatalf("first rotation: reused=%v err=%v", reused, err)
+	}
+	want := refreshRecord{loginID: "alice", device: "ios", accessToken: "access-1"}
+	if rec != want {
+		t.Fatalf("record = %+v, want %+v", rec, want)
+	}
+
+	// the client got a new pair; replaying the old refresh token is reuse
+	if err := store.add(ctx, "alice", "ios", "access-2", "refresh-2"); err != nil {
+		t.Fatal(err)
+	}
+	rec, reused, err = store.rotate(ctx, "refresh-1")
+	if err != nil || !reused {
+		t.Fatalf("replay: reused=%v err=%v, want reuse", reused, err)
+	}
+	if rec.loginID != "alice" || rec.device != "ios" {
+		t.Fatalf("replay record = %+v", rec)
+	}
+
+	if _, _, err := store.rotate(ctx, "never-issued"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("unknown token: err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestTokenStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store, now := newTestStore(t)
+	if err := store.add(ctx, "alice", "ios", "access-1", "refresh-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	*now = now.Add(refreshTTL)
+	if _, _, err := store.rotate(ctx, "refresh-1"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expired token: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, ok, _ := store.familyOfAccessToken(ctx, "access-1"); ok {
+		t.Fatal("expired token still maps its access token to a family")
+	}
+
+	// the next issue prunes expired rows
+	if err := store.add(ctx, "bob", "web", "access-2", "refresh-2"); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	store.db.Model(&entity.RefreshTokenEntity{}).Count(&n)
+	if n != 1 {
+		t.Fatalf("%d rows after pruning, want 1", n)
+	}
+}
+
+func TestTokenStoreFamilyOfAccessToken(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	_ = store.add(ctx, "alice", "ios", "access-1", "refresh-1")
+
+	loginID, device, ok, err := store.familyOfAccessToken(ctx, "access-1")
+	if err != nil || !ok || loginID != "alice" || device != "ios" {
+		t.Fatalf("familyOfAccessToken = %q %q %v %v", loginID, device, ok, err)
+	}
+
+	// a rotated token's access token is no longer the family's current one
+	if _, _, err := store.rotate(ctx, "refresh-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok, _ := store.familyOfAccessToken(ctx, "access-1"); ok {
+		t.Fatal("rotated token still maps its access token to a family")
+	}
+}
+
+func TestTokenStoreRevoke(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	_ = store.add(ctx, "alice", "ios", "access-1", "refresh-1")
+	_ = store.add(ctx, "alice", "ios", "access-2", "refresh-2")
+	_ = store.add(ctx, "alice", "web", "access-3", "refresh-3")
+
+	got, err := store.revoke(ctx, "alice", "ios")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"access-1", "access-2"}) {
+		t.Fatalf("revoked access tokens = %v", got)
+	}
+	if _, _, err := store.rotate(ctx, "refresh-1"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("revoked token: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, reused, err := store.rotate(ctx, "refresh-3"); err != nil || reused {
+		t.Fatalf("other device's token: reused=%v err=%v", reused, err)
+	}
+}
+
+func TestTokenStoreHashesRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	_ = store.add(ctx, "alice", "ios", "access-1", "refresh-1")
+
+	var row entity.RefreshTokenEntity
+	if err := store.db.Take(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.TokenHash == "refresh-1" || row.TokenHash != hashToken("refresh-1") {
+		t.Fatalf("token_hash = %q, want the SHA-256 of the token", row.TokenHash)
+	}
+}
@@ -3,31 +3,58 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/wiidz/gin_template/internal/common/logger"
+	"github.com/wiidz/gin_template/internal/common/tracing"
 	"github.com/wiidz/gin_template/internal/domain/shared/user/dto"
 	"github.com/wiidz/gin_template/internal/domain/shared/user/entity"
 	"github.com/wiidz/gin_template/internal/domain/shared/user/model"
 
+	"github.com/click33/sa-token-go/stputil"
+	"github.com/jackc/pgx/v5/pgconn"
 	idmng "github.com/wiidz/goutil/mngs/identityMng"
 	repoMng "github.com/wiidz/goutil/mngs/repoMng"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 var (
-	ErrInvalidCredentials = errors.New("invalid login credentials")
+	ErrInvalidCredentials  = errors.New("invalid login credentials")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrInvalidSort         = errors.New("invalid sort field")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrLoginIDTaken        = errors.New("login id already exists")
 )
 
+var tracer = tracing.Tracer("usersvc")
+
+// userSortColumns whitelists the columns the console may sort by.
+var userSortColumns = map[string]struct{}{
+	"id":         {},
+	"login_id":   {},
+	"nickname":   {},
+	"created_at": {},
+	"updated_at": {},
+}
+
 type Service struct {
-	users *repoMng.Repo[entity.UserEntity]
-	auth  *idmng.IdentityMng
+	users  *repoMng.Repo[entity.UserEntity]
+	auth   *idmng.IdentityMng
+	tokens *TokenStore
 }
 
-func New(users *repoMng.Repo[entity.UserEntity], auth *idmng.IdentityMng) *Service {
-	return &Service{users: users, auth: auth}
+func New(users *repoMng.Repo[entity.UserEntity], tokens *TokenStore, auth *idmng.IdentityMng) *Service {
+	return &Service{users: users, auth: auth, tokens: tokens}
 }
 
-func (s *Service) Login(ctx context.Context, req dto.LoginRequest) (dto.TokenPair, error) {
+func (s *Service) Login(ctx context.Context, req dto.LoginRequest) (_ dto.TokenPair, err error) {
+	ctx, span := tracer.Start(ctx, "usersvc.Login")
+	defer func() { tracing.End(span, err) }()
+
 	device := req.Device
 	if device == "" {
 		device = "client"
@@ -39,6 +66,7 @@ func (s *Service) Login(ctx context.Context, req dto.LoginRequest) (dto.TokenPai
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		logger.FromContext(ctx).Named("usersvc").Warn("login_failed", zap.String("login_id", req.LoginID), zap.String("device", device))
 		return dto.TokenPair{}, ErrInvalidCredentials
 	}
 
@@ -46,13 +74,337 @@ func (s *Service) Login(ctx context.Context, req dto.LoginRequest) (dto.TokenPai
 	if err != nil {
 		return dto.TokenPair{}, err
 	}
-	return dto.TokenPair{AccessToken: pair.AccessToken, RefreshToken: pair.RefreshToken}, nil
+	logger.FromContext(ctx).Named("usersvc").Info("login", zap.String("login_id", req.LoginID), zap.String("device", device))
+	return s.issue(ctx, req.LoginID, device, pair)
 }
 
-func (s *Service) Logout(ctx context.Context) error { return s.auth.Logout(ctx) }
+// Refresh redeems a refresh token for a new token pair. Each refresh token
+// can be used once; presenting an already-rotated token revokes every token
+// issued to that LoginID/device.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (_ dto.TokenPair, err error) {
+	ctx, span := tracer.Start(ctx, "usersvc.Refresh")
+	defer func() { tracing.End(span, err) }()
+
+	rec, reused, err := s.tokens.rotate(ctx, refreshToken)
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	if reused {
+		logger.FromContext(ctx).Named("usersvc").Warn("refresh_token_reused", zap.String("login_id", rec.loginID), zap.String("device", rec.device))
+		if err := s.revokeFamily(ctx, rec.loginID, rec.device); err != nil {
+			return dto.TokenPair{}, err
+		}
+		return dto.TokenPair{}, ErrRefreshTokenReused
+	}
+
+	// the user may have been deleted through another port or replica
+	if _, err := s.users.First(ctx, repoMng.WithEq("login_id", rec.loginID)); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return dto.TokenPair{}, err
+		}
+		if err := s.revokeFamily(ctx, rec.loginID, rec.device); err != nil {
+			return dto.TokenPair{}, err
+		}
+		return dto.TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	_ = stputil.RevokeRefreshToken(refreshToken)
+	_ = stputil.LogoutByToken(rec.accessToken)
+
+	pair, err := s.auth.RefreshByLoginID(ctx, rec.loginID, rec.device)
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	return s.issue(ctx, rec.loginID, rec.device, pair)
+}
+
+// Logout ends the session of accessToken and revokes its refresh token family.
+func (s *Service) Logout(ctx context.Context, accessToken string) (err error) {
+	ctx, span := tracer.Start(ctx, "usersvc.Logout")
+	defer func() { tracing.End(span, err) }()
+
+	loginID, device, ok, err := s.tokens.familyOfAccessToken(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := s.revokeFamily(ctx, loginID, device); err != nil {
+			return err
+		}
+	}
+	return stputil.LogoutByToken(accessToken)
+}
 
 func (s *Service) CurrentLoginID(ctx context.Context) string { return s.auth.CurrentLoginID(ctx) }
 
+// issue registers the access token with sa-token (LoginWithRefreshToken only
+// generates the pair) and tracks the refresh token for rotation.
+func (s *Service) issue(ctx context.Context, loginID, device string, pair idmng.TokenPair) (dto.TokenPair, error) {
+	if err := stputil.LoginByToken(loginID, pair.AccessToken, device); err != nil {
+		return dto.TokenPair{}, err
+	}
+	if err := s.tokens.add(ctx, loginID, device, pair.AccessToken, pair.RefreshToken); err != nil {
+		_ = stputil.LogoutByToken(pair.AccessToken)
+		return dto.TokenPair{}, err
+	}
+	return dto.TokenPair{AccessToken: pair.AccessToken, RefreshToken: pair.RefreshToken}, nil
+}
+
+func (s *Service) revokeFamily(ctx context.Context, loginID, device string) error {
+	accessTokens, err := s.tokens.revoke(ctx, loginID, device)
+	if err != nil {
+		return err
+	}
+	for _, token := range accessTokens {
+		_ = stputil.LogoutByToken(token)
+	}
+	_ = stputil.Logout(loginID, device)
+	return nil
+}
+
+// revokeUser ends every session of loginID and revokes the refresh token
+// families of all its devices.
+func (s *Service) revokeUser(ctx context.Context, loginID string) error {
+	devices, err := s.tokens.devices(ctx, loginID)
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		if err := s.revokeFamily(ctx, loginID, device); err != nil {
+			return err
+		}
+	}
+	_ = s.auth.LogoutByLoginID(ctx, loginID)
+	return nil
+}
+
+func (s *Service) Get(ctx context.Context, id uint64) (_ *model.User, err error) {
+	ctx, span := tracer.Start(ctx, "usersvc.Get")
+	defer func() { tracing.End(span, err) }()
+
+	ue, err := s.getEntity(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toModel(ue), nil
+}
+
+func (s *Service) Create(ctx context.Context, req dto.CreateUserRequest) (_ *model.User, err error) {
+	ctx, span := tracer.Start(ctx, "usersvc.Create")
+	defer func() { tracing.End(span, err) }()
+
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureLoginIDFree(ctx, req.LoginID, 0); err != nil {
+		return nil, err
+	}
+	ue := &entity.UserEntity{LoginID: req.LoginID, Nickname: req.Nickname, PasswordHash: hash}
+	if err := s.users.Create(ctx, ue); err != nil {
+		return nil, mapWriteError(err)
+	}
+	return toModel(ue), nil
+}
+
+func (s *Service) Update(ctx context.Context, id uint64, req dto.UpdateUserRequest) (_ *model.User, err error) {
+	ctx, span := tracer.Start(ctx, "usersvc.Update")
+	defer func() { tracing.End(span, err) }()
+
+	ue, err := s.getEntity(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var cols []string
+	oldLoginID := ue.LoginID
+	if req.LoginID != nil && *req.LoginID != ue.LoginID {
+		if err := s.ensureLoginIDFree(ctx, *req.LoginID, id); err != nil {
+			return nil, err
+		}
+		ue.LoginID = *req.LoginID
+		cols = append(cols, "login_id")
+	}
+	if req.Nickname != nil {
+		ue.Nickname = *req.Nickname
+		cols = append(cols, "nickname")
+	}
+	if len(cols) == 0 {
+		return toModel(ue), nil
+	}
+	if err := s.users.Update(ctx, ue, append(cols, "updated_at")...); err != nil {
+		return nil, mapWriteError(err)
+	}
+	// sessions and refresh tokens are bound to the old login ID
+	if ue.LoginID != oldLoginID {
+		if err := s.revokeUser(ctx, oldLoginID); err != nil {
+			return nil, err
+		}
+	}
+	return toModel(ue), nil
+}
+
+// ResetPassword replaces the password hash, ends all sessions of the user
+// and revokes its refresh tokens.
+func (s *Service) ResetPassword(ctx context.Context, id uint64, password string) (err error) {
+	ctx, span := tracer.Start(ctx, "usersvc.ResetPassword")
+	defer func() { tracing.End(span, err) }()
+
+	ue, err := s.getEntity(ctx, id)
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	ue.PasswordHash = hash
+	if err := s.users.Update(ctx, ue, "password_hash", "updated_at"); err != nil {
+		return err
+	}
+	return s.revokeUser(ctx, ue.LoginID)
+}
+
+func (s *Service) Delete(ctx context.Context, id uint64) (err error) {
+	ctx, span := tracer.Start(ctx, "usersvc.Delete")
+	defer func() { tracing.End(span, err) }()
+
+	ue, err := s.getEntity(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.users.Delete(ctx, repoMng.WithEq("id", id)); err != nil {
+		return err
+	}
+	return s.revokeUser(ctx, ue.LoginID)
+}
+
+// List returns one page of users matching q along with the total count.
+func (s *Service) List(ctx context.Context, q dto.ListUsersQuery) (_ []*model.User, _ int64, err error) {
+	ctx, span := tracer.Start(ctx, "usersvc.List")
+	defer func() { tracing.End(span, err) }()
+
+	order, err := parseSort(q.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := []repoMng.Selector{repoMng.WithOrder(order), repoMng.WithPage(q.Page, q.PageSize)}
+	if q.LoginID != "" {
+		prefix := escapeLike(q.LoginID) + "%"
+		opts = append(opts, repoMng.WithScopes(func(db *gorm.DB) *gorm.DB {
+			return db.Where(`login_id LIKE ? ESCAPE '\'`, prefix)
+		}))
+	}
+	if q.Nickname != "" {
+		contains := "%" + escapeLike(q.Nickname) + "%"
+		opts = append(opts, repoMng.WithScopes(func(db *gorm.DB) *gorm.DB {
+			return db.Where(`nickname LIKE ? ESCAPE '\'`, contains)
+		}))
+	}
+	if q.CreatedFrom != nil {
+		from := *q.CreatedFrom
+		opts = append(opts, repoMng.WithScopes(func(db *gorm.DB) *gorm.DB {
+			return db.Where("created_at >= ?", from)
+		}))
+	}
+	if q.CreatedTo != nil {
+		to := *q.CreatedTo
+		opts = append(opts, repoMng.WithScopes(func(db *gorm.DB) *gorm.DB {
+			return db.Where("created_at <= ?", to)
+		}))
+	}
+
+	rows, total, err := s.users.List(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	users := make([]*model.User, 0, len(rows))
+	for _, ue := range rows {
+		users = append(users, toModel(ue))
+	}
+	return users, total, nil
+}
+
+// parseSort turns "-created_at,login_id" into "created_at DESC, login_id ASC".
+func parseSort(sort string) (string, error) {
+	var parts []string
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		dir := "ASC"
+		if strings.HasPrefix(field, "-") {
+			dir = "DESC"
+			field = field[1:]
+		} else {
+			field = strings.TrimPrefix(field, "+")
+		}
+		if _, ok := userSortColumns[field]; !ok {
+			return "", fmt.Errorf("%w: %s", ErrInvalidSort, field)
+		}
+		parts = append(parts, field+" "+dir)
+	}
+	if len(parts) == 0 {
+		return "id DESC", nil
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// likeEscaper escapes LIKE wildcards; the queries name the escape character
+// since SQLite has no default one.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string { return likeEscaper.Replace(s) }
+
+func (s *Service) getEntity(ctx context.Context, id uint64) (*entity.UserEntity, error) {
+	ue, err := s.users.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return ue, nil
+}
+
+// ensureLoginIDFree fails with ErrLoginIDTaken when another user (other than
+// exceptID) owns loginID. The unique index remains the source of truth; see
+// mapWriteError for races between this check and the write.
+func (s *Service) ensureLoginIDFree(ctx context.Context, loginID string, exceptID uint64) error {
+	ue, err := s.users.First(ctx, repoMng.WithEq("login_id", loginID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if ue.ID != exceptID {
+		return ErrLoginIDTaken
+	}
+	return nil
+}
+
+// mapWriteError translates unique violations on login_id into ErrLoginIDTaken.
+func mapWriteError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrLoginIDTaken
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrLoginIDTaken
+	}
+	return err
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
 func (s *Service) findUser(ctx context.Context, loginID string) (*model.User, error) {
 	ue, err := s.users.First(ctx, repoMng.WithEq("login_id", loginID))
 	if err != nil {
@@ -65,10 +417,16 @@ func (s *Service) findUser(ctx context.Context, loginID string) (*model.User, er
 		return nil, ErrInvalidCredentials
 	}
 
+	return toModel(ue), nil
+}
+
+func toModel(ue *entity.UserEntity) *model.User {
 	return &model.User{
 		ID:           ue.ID,
 		LoginID:      ue.LoginID,
 		Nickname:     ue.Nickname,
 		PasswordHash: ue.PasswordHash,
-	}, nil
+		CreatedAt:    ue.CreatedAt,
+		UpdatedAt:    ue.UpdatedAt,
+	}
 }
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	idmng "github.com/wiidz/goutil/mngs/identityMng"
+	"github.com/wiidz/goutil/mngs/repoMng"
+
+	"github.com/wiidz/gin_template/internal/domain/shared/user/dto"
+	"github.com/wiidz/gin_template/internal/domain/shared/user/entity"
+)
+
+// newTestServices returns a client and a console Service sharing one
+// database, as the two ports do, plus a user logged in on two devices.
+func newTestServices(t *testing.T) (client, console *Service, userID uint64, ios, web dto.TokenPair) {
+	t.Helper()
+	db := newTestDB(t)
+	mng, err := idmng.NewMng(&idmng.Config{DefaultDevice: "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := repoMng.RepoOf[entity.UserEntity](db)
+	client = New(users, NewTokenStore(db), mng)
+	console = New(users, NewTokenStore(db), mng)
+
+	ctx := context.Background()
+	u, err := console.Create(ctx, dto.CreateUserRequest{LoginID: "alice", Password: "password1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ios, err = client.Login(ctx, dto.LoginRequest{LoginID: "alice", Password: "password1", Device: "ios"}); err != nil {
+		t.Fatal(err)
+	}
+	if web, err = client.Login(ctx, dto.LoginRequest{LoginID: "alice", Password: "password1", Device: "web"}); err != nil {
+		t.Fatal(err)
+	}
+	return client, console, u.ID, ios, web
+}
+
+func TestServiceRefresh(t *testing.T) {
+	ctx := context.Background()
+	client, _, _, ios, _ := newTestServices(t)
+
+	next, err := client.Refresh(ctx, ios.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.RefreshToken == ios.RefreshToken || next.AccessToken == ios.AccessToken {
+		t.Fatal("refresh returned the old pair")
+	}
+	if _, err := client.Refresh(ctx, ios.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replay: err = %v, want ErrRefreshTokenReused", err)
+	}
+	// reuse revoked the family, including the pair just issued
+	if _, err := client.Refresh(ctx, next.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("after reuse: err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestServiceRevokesAllDevices(t *testing.T) {
+	ctx := context.Background()
+	newLoginID := "alice2"
+	tests := []struct {
+		name   string
+		revoke func(console *Service, id uint64) error
+	}{
+		{"reset password", func(console *Service, id uint64) error {
+			return console.ResetPassword(ctx, id, "password2")
+		}},
+		{"delete", func(console *Service, id uint64) error {
+			return console.Delete(ctx, id)
+		}},
+		{"login id change", func(console *Service, id uint64) error {
+			_, err := console.Update(ctx, id, dto.UpdateUserRequest{LoginID: &newLoginID})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, console, id, ios, web := newTestServices(t)
+			if err := tt.revoke(console, id); err != nil {
+				t.Fatal(err)
+			}
+			for _, pair := range []dto.TokenPair{ios, web} {
+				if _, err := client.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+					t.Fatalf("refresh after %s: err = %v, want ErrInvalidRefreshToken", tt.name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestServiceRefreshChecksUserExists(t *testing.T) {
+	ctx := context.Background()
+	client, console, id, ios, _ := newTestServices(t)
+
+	// removed behind the service's back, e.g. by another replica mid-delete
+	if err := console.users.Delete(ctx, repoMng.WithEq("id", id)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Refresh(ctx, ios.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("refresh of a deleted user: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if devices, _ := client.tokens.devices(ctx, "alice"); len(devices) != 1 || devices[0] != "web" {
+		t.Fatalf("devices after refresh = %v, want only web left", devices)
+	}
+}